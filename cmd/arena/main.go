@@ -0,0 +1,360 @@
+// Command arena pits two strategies against each other, reporting the results.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ascii8/xoxo-go/solver"
+	"github.com/ascii8/xoxo-go/xoxo"
+	"golang.org/x/sync/errgroup"
+)
+
+func main() {
+	a := flag.String("a", "random", "first strategy ("+strings.Join(solver.Strategies, ", ")+")")
+	b := flag.String("b", "greedy", "second strategy ("+strings.Join(solver.Strategies, ", ")+")")
+	games := flag.Int("games", 100, "game count")
+	variant := flag.String("variant", "", "board variant (RxCxK)")
+	seed := flag.Int64("seed", 0, "seed")
+	moveTime := flag.Duration("movetime", 0, "move time limit")
+	online := flag.Bool("online", false, "play the games via the server")
+	urlstr := flag.String("url", "http://127.0.0.1:7350", "xoxo host")
+	key := flag.String("key", "xoxo-go_server", "server key")
+	jsonOut := flag.Bool("json", false, "write the report as json")
+	flag.Parse()
+	if err := run(context.Background(), *a, *b, *games, *variant, *seed, *moveTime, *online, *urlstr, *key, *jsonOut); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, a, b string, games int, variant string, seed int64, moveTime time.Duration, online bool, urlstr, key string, jsonOut bool) error {
+	if games < 1 {
+		return fmt.Errorf("invalid game count %d", games)
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	v := xoxo.DefaultVariant
+	if variant != "" {
+		var err error
+		if v, err = xoxo.ParseVariant(variant); err != nil {
+			return err
+		}
+	}
+	r := rand.New(rand.NewSource(seed))
+	ar := newArena(v, moveTime)
+	for i, name := range []string{a, b} {
+		s, err := solver.ParseStrategy(name, rand.New(rand.NewSource(r.Int63())))
+		if err != nil {
+			return err
+		}
+		ar.players[i] = &player{
+			name:     name,
+			strategy: s,
+		}
+	}
+	var err error
+	switch {
+	case online:
+		err = ar.playOnline(ctx, urlstr, key, games)
+	default:
+		err = ar.playOffline(ctx, games)
+	}
+	if err != nil {
+		return err
+	}
+	rep := ar.report(seed)
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	return rep.Write(os.Stdout)
+}
+
+// arena plays games between two players.
+type arena struct {
+	variant  xoxo.Variant
+	moveTime time.Duration
+	players  [2]*player
+	results  []result
+	mu       sync.Mutex
+}
+
+// player is an arena player.
+type player struct {
+	name      string
+	strategy  xoxo.Strategy
+	latencies []time.Duration
+}
+
+// result is the result of a game, with the players referred to by index.
+type result struct {
+	// first is the player that moved first.
+	first int
+	// winner is the winning player, or -1 for a draw.
+	winner int
+	moves  int
+}
+
+// newArena creates an arena for the variant.
+func newArena(variant xoxo.Variant, moveTime time.Duration) *arena {
+	return &arena{
+		variant:  variant,
+		moveTime: moveTime,
+	}
+}
+
+// choose chooses the player's move, recording its latency.
+func (ar *arena) choose(ctx context.Context, i int, state *xoxo.State) (xoxo.Move, error) {
+	if ar.moveTime != 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, ar.moveTime)
+		defer cancel()
+	}
+	start := time.Now()
+	move, err := ar.players[i].strategy.ChooseMove(ctx, state)
+	d := time.Since(start)
+	if err != nil {
+		return xoxo.Move{}, fmt.Errorf("%s: %w", ar.players[i].name, err)
+	}
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.players[i].latencies = append(ar.players[i].latencies, d)
+	return move, nil
+}
+
+// playOffline plays the games directly on a state, alternating the player
+// moving first.
+func (ar *arena) playOffline(ctx context.Context, games int) error {
+	for g := 0; g < games; g++ {
+		first := g % 2
+		state, err := xoxo.NewVariantState(ar.variant)
+		if err != nil {
+			return err
+		}
+		// seat 0 is player 1, who moves first
+		seats := [2]int{first, 1 - first}
+		for _, i := range seats {
+			if err := state.Add("", "", strconv.Itoa(i), ar.players[i].name); err != nil {
+				return err
+			}
+		}
+		for state.Winner == 0 && !state.Draw {
+			i := seats[state.PlayerTurn-1]
+			move, err := ar.choose(ctx, i, state.Copy())
+			if err != nil {
+				return err
+			}
+			if err := state.Move(strconv.Itoa(i), move); err != nil {
+				return fmt.Errorf("%s: %w", ar.players[i].name, err)
+			}
+		}
+		res := result{
+			first:  first,
+			winner: -1,
+			moves:  len(state.Moves),
+		}
+		if !state.Draw {
+			res.winner = seats[state.Winner-1]
+		}
+		ar.results = append(ar.results, res)
+	}
+	return nil
+}
+
+// playOnline plays the games with a client per player, matched by the
+// server's matchmaker, voting for rematches between games. The server
+// alternates the player moving first.
+func (ar *arena) playOnline(ctx context.Context, urlstr, key string, games int) error {
+	var clients [2]*xoxo.Client
+	for i := range clients {
+		cl, err := xoxo.Dial(ctx, xoxo.WithURL(urlstr), xoxo.WithServerKey(key))
+		if err != nil {
+			return err
+		}
+		defer cl.Close()
+		clients[i] = cl
+	}
+	// the first player's game state, as seen from its moves
+	var num int
+	var moved bool
+	eg, ctx := errgroup.WithContext(ctx)
+	for i := range clients {
+		i, cl := i, clients[i]
+		opts := []xoxo.BotOption{
+			xoxo.WithBotGames(games),
+			xoxo.WithBotJoin(xoxo.WithJoinVariant(ar.variant)),
+			xoxo.WithBotLogf(log.Printf),
+		}
+		if i == 0 {
+			opts = append(opts, xoxo.WithBotResult(func(_ int, state *xoxo.MatchState) {
+				res := result{
+					first:  1,
+					winner: -1,
+					moves:  len(state.State.Moves),
+				}
+				if moved {
+					res.first = 0
+				}
+				switch {
+				case state.State.Winner == xoxo.Winner(num):
+					res.winner = 0
+				case !state.State.Draw:
+					res.winner = 1
+				}
+				ar.mu.Lock()
+				ar.results = append(ar.results, res)
+				ar.mu.Unlock()
+				moved = false
+			}))
+		}
+		strategy := func(ctx context.Context, state *xoxo.State) (xoxo.Move, error) {
+			if i == 0 {
+				num, moved = state.PlayerTurn, moved || len(state.Moves) == 0
+			}
+			return ar.choose(ctx, i, state)
+		}
+		eg.Go(func() error {
+			if err := xoxo.NewBot(cl, xoxo.StrategyFunc(strategy), opts...).Run(ctx); err != nil {
+				return err
+			}
+			return cl.Leave(ctx)
+		})
+	}
+	return eg.Wait()
+}
+
+// report creates the report of the games played.
+func (ar *arena) report(seed int64) *Report {
+	rep := &Report{
+		Variant: ar.variant.String(),
+		Seed:    seed,
+		Games:   len(ar.results),
+	}
+	for i, p := range ar.players {
+		rep.Players = append(rep.Players, &PlayerReport{
+			Strategy: fmt.Sprintf("%s (%c)", p.name, 'a'+i),
+			Latency:  newLatency(p.latencies),
+		})
+	}
+	moves := 0
+	for _, res := range ar.results {
+		moves += res.moves
+		rep.Players[res.first].First++
+		switch {
+		case res.winner == -1:
+			rep.Draws++
+			rep.Players[0].Draws++
+			rep.Players[1].Draws++
+		case res.winner == res.first:
+			rep.FirstWins++
+			rep.Players[res.winner].Wins++
+			rep.Players[res.winner].FirstWins++
+			rep.Players[1-res.winner].Losses++
+		default:
+			rep.SecondWins++
+			rep.Players[res.winner].Wins++
+			rep.Players[1-res.winner].Losses++
+		}
+	}
+	if rep.Games != 0 {
+		rep.AvgMoves = float64(moves) / float64(rep.Games)
+	}
+	return rep
+}
+
+// Report is an arena report.
+type Report struct {
+	Variant    string          `json:"variant"`
+	Seed       int64           `json:"seed"`
+	Games      int             `json:"games"`
+	FirstWins  int             `json:"first_wins"`
+	SecondWins int             `json:"second_wins"`
+	Draws      int             `json:"draws"`
+	AvgMoves   float64         `json:"avg_moves"`
+	Players    []*PlayerReport `json:"players"`
+}
+
+// PlayerReport is a player's results.
+type PlayerReport struct {
+	Strategy string `json:"strategy"`
+	Wins     int    `json:"wins"`
+	Draws    int    `json:"draws"`
+	Losses   int    `json:"losses"`
+	// First is the number of games the player moved first.
+	First     int     `json:"first"`
+	FirstWins int     `json:"first_wins"`
+	Latency   Latency `json:"latency"`
+}
+
+// Latency are move latency statistics, with the durations encoded in
+// nanoseconds.
+type Latency struct {
+	Moves int           `json:"moves"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	Max   time.Duration `json:"max"`
+}
+
+// newLatency creates the latency statistics for the durations.
+func newLatency(v []time.Duration) Latency {
+	if len(v) == 0 {
+		return Latency{}
+	}
+	v = append([]time.Duration(nil), v...)
+	sort.Slice(v, func(i, j int) bool { return v[i] < v[j] })
+	var total time.Duration
+	for _, d := range v {
+		total += d
+	}
+	return Latency{
+		Moves: len(v),
+		Mean:  total / time.Duration(len(v)),
+		P50:   v[(len(v)-1)*50/100],
+		P95:   v[(len(v)-1)*95/100],
+		Max:   v[len(v)-1],
+	}
+}
+
+// Write writes the report as a table.
+func (rep *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "variant %s, seed %d, %d games\n", rep.Variant, rep.Seed, rep.Games)
+	fmt.Fprintf(
+		tw, "first player wins %s, second player wins %s, draws %s, avg length %.1f moves\n\n",
+		pct(rep.FirstWins, rep.Games), pct(rep.SecondWins, rep.Games), pct(rep.Draws, rep.Games), rep.AvgMoves,
+	)
+	fmt.Fprintln(tw, "strategy\twin\tdraw\tloss\twin first\tmoves\tmean\tp50\tp95\tmax")
+	for _, p := range rep.Players {
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			p.Strategy, pct(p.Wins, rep.Games), pct(p.Draws, rep.Games), pct(p.Losses, rep.Games),
+			pct(p.FirstWins, p.First), p.Latency.Moves,
+			p.Latency.Mean, p.Latency.P50, p.Latency.P95, p.Latency.Max,
+		)
+	}
+	return tw.Flush()
+}
+
+// pct formats n of total as a percentage.
+func pct(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
@@ -9,6 +9,7 @@ import (
 	"syscall"
 
 	"github.com/ascii8/xoxo-go/gioxoxo"
+	"github.com/ascii8/xoxo-go/xoxo"
 	"github.com/rs/zerolog"
 )
 
@@ -16,14 +17,19 @@ func main() {
 	debug := flag.Bool("debug", true, "enable debug")
 	urlstr := flag.String("url", "http://127.0.0.1:7350", "xoxo host")
 	key := flag.String("key", "xoxo-go_server", "server key")
+	mode := flag.String("mode", "online", "game mode (online, hotseat, ai)")
 	flag.Parse()
-	if err := run(context.Background(), *debug, *urlstr, *key); err != nil {
+	if err := run(context.Background(), *debug, *mode, *urlstr, *key); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context, debug bool, urlstr, key string) error {
+func run(ctx context.Context, debug bool, modestr, urlstr, key string) error {
+	mode, err := xoxo.ParseMode(modestr)
+	if err != nil {
+		return err
+	}
 	level := zerolog.Disabled
 	if s := os.Getenv("LEVEL"); s != "" {
 		if l, err := zerolog.ParseLevel(s); err == nil {
@@ -61,7 +67,7 @@ func run(ctx context.Context, debug bool, urlstr, key string) error {
 			cancel()
 		}
 	}()
-	if err := gioxoxo.Run(ctx, logger, debug, urlstr, key); err != nil {
+	if err := gioxoxo.Run(ctx, logger, debug, mode, urlstr, key); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,420 @@
+// Command loadtest drives concurrent clients through matchmaking, moves and
+// rematches against the xoxo module, reporting latency and error statistics.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ascii8/xoxo-go/solver"
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func main() {
+	urlstr := flag.String("url", "http://127.0.0.1:7350", "xoxo host")
+	key := flag.String("key", "xoxo-go_server", "server key")
+	clients := flag.Int("clients", 100, "concurrent client count")
+	ramp := flag.Duration("ramp", 10*time.Second, "time over which the clients are started")
+	duration := flag.Duration("duration", time.Minute, "test duration")
+	games := flag.Int("games", 3, "games played per match before leaving")
+	think := flag.Duration("think", 500*time.Millisecond, "delay before each move, with up to the same again of random jitter")
+	timeout := flag.Duration("timeout", 2*time.Minute, "match timeout")
+	interval := flag.Duration("interval", 10*time.Second, "progress report interval")
+	variant := flag.String("variant", "", "board variant (RxCxK)")
+	strategy := flag.String("strategy", "random", "move strategy ("+strings.Join(solver.Strategies, ", ")+")")
+	seed := flag.Int64("seed", 0, "seed")
+	jsonOut := flag.Bool("json", false, "write the report as json")
+	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	lt := &loadTest{
+		urlstr:   *urlstr,
+		key:      *key,
+		clients:  *clients,
+		ramp:     *ramp,
+		games:    *games,
+		think:    *think,
+		timeout:  *timeout,
+		interval: *interval,
+		strategy: *strategy,
+		seed:     *seed,
+		stats:    new(stats),
+	}
+	if err := run(ctx, lt, *duration, *variant, *jsonOut); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, lt *loadTest, duration time.Duration, variant string, jsonOut bool) error {
+	if lt.clients < 1 {
+		return fmt.Errorf("invalid client count %d", lt.clients)
+	}
+	if lt.seed == 0 {
+		lt.seed = time.Now().UnixNano()
+	}
+	lt.variant = xoxo.DefaultVariant
+	if variant != "" {
+		var err error
+		if lt.variant, err = xoxo.ParseVariant(variant); err != nil {
+			return err
+		}
+	}
+	if _, err := solver.ParseStrategy(lt.strategy, nil); err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := lt.Run(ctx, duration); err != nil {
+		return err
+	}
+	rep := lt.stats.report(time.Since(start))
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	return rep.Write(os.Stdout)
+}
+
+// loadTest runs the clients.
+type loadTest struct {
+	urlstr   string
+	key      string
+	clients  int
+	ramp     time.Duration
+	games    int
+	think    time.Duration
+	timeout  time.Duration
+	interval time.Duration
+	variant  xoxo.Variant
+	strategy string
+	seed     int64
+	stats    *stats
+}
+
+// Run starts the clients, spreading their start over the ramp, and runs them
+// for the duration.
+func (lt *loadTest) Run(ctx context.Context, duration time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	r := rand.New(rand.NewSource(lt.seed))
+	var wg sync.WaitGroup
+	go lt.progress(ctx)
+	for i := 0; i < lt.clients; i++ {
+		if i != 0 && lt.ramp != 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(lt.ramp / time.Duration(lt.clients)):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		w := &worker{
+			lt: lt,
+			r:  rand.New(rand.NewSource(r.Int63())),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// progress logs the stats at every interval until the context is done.
+func (lt *loadTest) progress(ctx context.Context) {
+	if lt.interval <= 0 {
+		return
+	}
+	t := time.NewTicker(lt.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			log.Printf("progress: %s", lt.stats)
+		}
+	}
+}
+
+// worker is a single client's loop.
+type worker struct {
+	lt *loadTest
+	r  *rand.Rand
+	// joined is when the client joined the matchmaker, and sent is when the
+	// client sent its last move, against the state with seq.
+	joined time.Time
+	sent   time.Time
+	seq    int64
+	mu     sync.Mutex
+}
+
+// run connects the client, and plays matches until the context is done,
+// reconnecting when the connection is lost.
+func (w *worker) run(ctx context.Context) {
+	strategy, err := solver.ParseStrategy(w.lt.strategy, rand.New(rand.NewSource(w.r.Int63())))
+	if err != nil {
+		w.lt.stats.add(func(s *stats) { s.Errors++ })
+		return
+	}
+	for ctx.Err() == nil {
+		cl, err := xoxo.Dial(ctx, xoxo.WithURL(w.lt.urlstr), xoxo.WithServerKey(w.lt.key))
+		if err != nil {
+			w.lt.stats.add(func(s *stats) { s.ConnectErrors++ })
+			w.sleep(ctx, time.Second)
+			continue
+		}
+		w.lt.stats.add(func(s *stats) { s.Connects++ })
+		events := cl.Events()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			w.watch(events)
+		}()
+		for ctx.Err() == nil && cl.Connected() {
+			if err := w.match(ctx, cl, strategy); err != nil && ctx.Err() == nil {
+				log.Printf("match: %v", err)
+				w.lt.stats.add(func(s *stats) { s.Errors++ })
+				w.sleep(ctx, time.Second)
+			}
+		}
+		cl.Close()
+		<-done
+	}
+}
+
+// match joins a match, and plays its games, leaving the match when done.
+func (w *worker) match(ctx context.Context, cl *xoxo.Client, strategy xoxo.Strategy) error {
+	defer cl.Leave(context.Background())
+	ctx, cancel := context.WithTimeout(ctx, w.lt.timeout)
+	defer cancel()
+	w.mu.Lock()
+	w.joined = time.Now()
+	w.mu.Unlock()
+	if err := cl.Join(ctx, xoxo.WithJoinVariant(w.lt.variant)); err != nil {
+		return fmt.Errorf("unable to join: %w", err)
+	}
+	for g := 0; g < w.lt.games; g++ {
+		if !cl.Ready(ctx) {
+			break
+		}
+		for cl.Next(ctx) {
+			if !w.sleep(ctx, w.lt.think+time.Duration(w.r.Int63n(int64(w.lt.think)+1))) {
+				break
+			}
+			// the state is cleared on disconnect or leave
+			state := cl.State()
+			if state == nil || state.State == nil {
+				break
+			}
+			move, err := strategy.ChooseMove(ctx, state.State.Copy())
+			if err != nil {
+				return fmt.Errorf("unable to choose move: %w", err)
+			}
+			w.mu.Lock()
+			w.sent, w.seq = time.Now(), state.Seq
+			w.mu.Unlock()
+			if err := cl.Move(ctx, move.Row-1, move.Col-1); err != nil {
+				return fmt.Errorf("unable to move: %w", err)
+			}
+		}
+		state := cl.State()
+		if state == nil || state.State == nil || (state.State.Winner == 0 && !state.State.Draw) {
+			break
+		}
+		w.lt.stats.add(func(s *stats) { s.Games++ })
+		if state.State.Finished || g+1 == w.lt.games {
+			break
+		}
+		if err := cl.Rematch(ctx, true); err != nil {
+			return fmt.Errorf("unable to rematch: %w", err)
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		w.lt.stats.add(func(s *stats) { s.Timeouts++ })
+	}
+	return nil
+}
+
+// watch records the client's events until the stream is closed.
+func (w *worker) watch(events <-chan xoxo.Event) {
+	for ev := range events {
+		now := time.Now()
+		w.mu.Lock()
+		switch ev.Type {
+		case xoxo.EventMatchFound:
+			if !w.joined.IsZero() {
+				d := now.Sub(w.joined)
+				w.lt.stats.add(func(s *stats) { s.Matches, s.waits = s.Matches+1, append(s.waits, d) })
+			}
+			w.joined = time.Time{}
+		case xoxo.EventStateChanged:
+			if !w.sent.IsZero() && ev.State != nil && ev.State.Seq > w.seq {
+				d := now.Sub(w.sent)
+				w.lt.stats.add(func(s *stats) { s.Moves, s.rtts = s.Moves+1, append(s.rtts, d) })
+				w.sent = time.Time{}
+			}
+		case xoxo.EventError:
+			w.lt.stats.add(func(s *stats) { s.Rejected++ })
+			w.sent = time.Time{}
+		case xoxo.EventDisconnected:
+			w.lt.stats.add(func(s *stats) { s.Disconnects++ })
+		}
+		w.mu.Unlock()
+	}
+}
+
+// sleep sleeps for the duration, returning false when the context is done
+// first.
+func (w *worker) sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// stats are the load test statistics.
+type stats struct {
+	Connects      int
+	ConnectErrors int
+	Disconnects   int
+	Matches       int
+	Games         int
+	Moves         int
+	Rejected      int
+	Timeouts      int
+	Errors        int
+	waits         []time.Duration
+	rtts          []time.Duration
+	mu            sync.Mutex
+}
+
+// add updates the stats with f.
+func (s *stats) add(f func(*stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s)
+}
+
+// String satisfies the fmt.Stringer interface.
+func (s *stats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf(
+		"connects %d (errors %d, disconnects %d), matches %d, games %d, moves %d (rejected %d), timeouts %d, errors %d",
+		s.Connects, s.ConnectErrors, s.Disconnects, s.Matches, s.Games, s.Moves, s.Rejected, s.Timeouts, s.Errors,
+	)
+}
+
+// report creates the report for the stats.
+func (s *stats) report(elapsed time.Duration) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &Report{
+		Elapsed:       elapsed,
+		Connects:      s.Connects,
+		ConnectErrors: s.ConnectErrors,
+		Disconnects:   s.Disconnects,
+		Matches:       s.Matches,
+		Games:         s.Games,
+		Moves:         s.Moves,
+		Rejected:      s.Rejected,
+		Timeouts:      s.Timeouts,
+		Errors:        s.Errors,
+		MatchWait:     newLatency(s.waits),
+		MoveRTT:       newLatency(s.rtts),
+	}
+}
+
+// Report is a load test report, with the durations encoded in nanoseconds.
+type Report struct {
+	Elapsed       time.Duration `json:"elapsed"`
+	Connects      int           `json:"connects"`
+	ConnectErrors int           `json:"connect_errors"`
+	Disconnects   int           `json:"disconnects"`
+	Matches       int           `json:"matches"`
+	Games         int           `json:"games"`
+	Moves         int           `json:"moves"`
+	Rejected      int           `json:"rejected"`
+	Timeouts      int           `json:"timeouts"`
+	Errors        int           `json:"errors"`
+	MatchWait     Latency       `json:"match_wait"`
+	MoveRTT       Latency       `json:"move_rtt"`
+}
+
+// Latency are latency statistics.
+type Latency struct {
+	Count int           `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// newLatency creates the latency statistics for the durations.
+func newLatency(v []time.Duration) Latency {
+	if len(v) == 0 {
+		return Latency{}
+	}
+	v = append([]time.Duration(nil), v...)
+	sort.Slice(v, func(i, j int) bool { return v[i] < v[j] })
+	var total time.Duration
+	for _, d := range v {
+		total += d
+	}
+	return Latency{
+		Count: len(v),
+		Mean:  total / time.Duration(len(v)),
+		P50:   v[(len(v)-1)*50/100],
+		P90:   v[(len(v)-1)*90/100],
+		P99:   v[(len(v)-1)*99/100],
+		Max:   v[len(v)-1],
+	}
+}
+
+// Write writes the report as a table.
+func (rep *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "elapsed\t%s\n", rep.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "connects\t%d\n", rep.Connects)
+	fmt.Fprintf(tw, "connect errors\t%d\n", rep.ConnectErrors)
+	fmt.Fprintf(tw, "disconnects\t%d\n", rep.Disconnects)
+	fmt.Fprintf(tw, "matches\t%d\n", rep.Matches)
+	fmt.Fprintf(tw, "games\t%d\n", rep.Games)
+	fmt.Fprintf(tw, "moves\t%d\n", rep.Moves)
+	fmt.Fprintf(tw, "rejected moves\t%d\n", rep.Rejected)
+	fmt.Fprintf(tw, "match timeouts\t%d\n", rep.Timeouts)
+	fmt.Fprintf(tw, "errors\t%d\n", rep.Errors)
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "latency\tcount\tmean\tp50\tp90\tp99\tmax")
+	for _, l := range []struct {
+		name string
+		Latency
+	}{{"match wait", rep.MatchWait}, {"move rtt", rep.MoveRTT}} {
+		fmt.Fprintf(
+			tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			l.name, l.Count, l.Mean, l.P50, l.P90, l.P99, l.Max,
+		)
+	}
+	return tw.Flush()
+}
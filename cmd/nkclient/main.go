@@ -7,8 +7,10 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/ascii8/xoxo-go/solver"
 	"github.com/ascii8/xoxo-go/xoxo"
 )
 
@@ -17,46 +19,115 @@ func main() {
 	key := flag.String("key", "xoxo-go_server", "server key")
 	seed := flag.Int64("seed", 0, "seed")
 	count := flag.Int("count", 3, "game count")
+	bot := flag.String("bot", "", "bot opponent level (easy, medium, hard)")
+	variant := flag.String("variant", "", "board variant (RxCxK)")
+	region := flag.String("region", "", "matchmaker region")
+	spectate := flag.String("spectate", "", "match id to spectate")
+	codec := flag.String("codec", "", "match message codec (json, binary)")
+	strategy := flag.String("strategy", "random", "move strategy ("+strings.Join(solver.Strategies, ", ")+")")
 	flag.Parse()
-	if err := run(context.Background(), *urlstr, *key, *seed, *count); err != nil {
+	f := func(ctx context.Context) error {
+		return run(ctx, *urlstr, *key, *seed, *count, *bot, *variant, *region, *codec, *strategy)
+	}
+	if *spectate != "" {
+		f = func(ctx context.Context) error {
+			return watch(ctx, *urlstr, *key, *spectate)
+		}
+	}
+	if err := f(context.Background()); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context, urlstr, key string, seed int64, count int) error {
+func run(ctx context.Context, urlstr, key string, seed int64, count int, bot, variant, region, codec, strategy string) error {
 	if seed == 0 {
 		seed = time.Now().UnixNano()
 	}
-	r := rand.New(rand.NewSource(seed))
-	cl, err := xoxo.Dial(ctx, xoxo.WithURL(urlstr), xoxo.WithServerKey(key), xoxo.WithLogf(log.Printf), xoxo.WithDebug())
+	s, err := solver.ParseStrategy(strategy, rand.New(rand.NewSource(seed)))
 	if err != nil {
 		return err
 	}
-	if err := cl.Join(ctx); err != nil {
+	opts := []xoxo.Option{xoxo.WithURL(urlstr), xoxo.WithServerKey(key), xoxo.WithLogf(log.Printf), xoxo.WithDebug()}
+	if bot != "" {
+		level, err := xoxo.ParseBotLevel(bot)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, xoxo.WithBot(level))
+	}
+	c, err := xoxo.ParseCodec(codec)
+	if err != nil {
 		return err
 	}
-	for i := 0; count < 1 || i < count; i++ {
-		for cl.Ready(ctx) && cl.Next(ctx) {
-			state := cl.State()
-			log.Printf("player turn %q (%d)", state.ActivePlayer.UserId, state.State.PlayerTurn)
-			v := state.State.Available()
-			n := r.Intn(len(v))
-			log.Printf(
-				"player %d available %d, choosing move %d (%d, %d)",
-				state.State.PlayerTurn, len(v), n, v[n][0], v[n][1],
-			)
-			if err := cl.Move(ctx, v[n][0], v[n][1]); err != nil {
-				return err
-			}
-		}
-		switch state := cl.State(); {
-		case state.State.Draw:
-			log.Printf("game %d: was a draw!", i+1)
-		default:
-			log.Printf("game %d: player %d won!", i+1, state.State.Winner)
+	opts = append(opts, xoxo.WithCodec(c))
+	var joinOpts []xoxo.JoinOption
+	if variant != "" {
+		v, err := xoxo.ParseVariant(variant)
+		if err != nil {
+			return err
 		}
+		joinOpts = append(joinOpts, xoxo.WithJoinVariant(v))
+	}
+	if region != "" {
+		joinOpts = append(joinOpts, xoxo.WithJoinRegion(region))
+	}
+	cl, err := xoxo.Dial(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	b := xoxo.NewBot(
+		cl, s,
+		xoxo.WithBotGames(count),
+		xoxo.WithBotJoin(joinOpts...),
+		xoxo.WithBotLogf(log.Printf),
+		xoxo.WithBotResult(func(game int, state *xoxo.MatchState) {
+			switch {
+			case state.State.Draw:
+				log.Printf("game %d: was a draw!", game)
+			default:
+				log.Printf("game %d: player %d won!", game, state.State.Winner)
+			}
+			if state.State.Series != nil {
+				log.Printf("series: %s", state.State.Series)
+			}
+		}),
+	)
+	if err := b.Run(ctx); err != nil {
+		return err
 	}
 	<-time.After(2 * time.Second)
 	return cl.Leave(ctx)
 }
+
+// watch spectates the match, logging its states until a player leaves.
+func watch(ctx context.Context, urlstr, key, matchId string) error {
+	cl, err := xoxo.Dial(ctx, xoxo.WithURL(urlstr), xoxo.WithServerKey(key), xoxo.WithLogf(log.Printf))
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	events := cl.Events()
+	if err := cl.Spectate(ctx, matchId); err != nil {
+		return err
+	}
+	for ev := range events {
+		switch ev.Type {
+		case xoxo.EventStateChanged:
+			log.Printf("state: %s", ev.State.State)
+		case xoxo.EventGameOver:
+			switch {
+			case ev.State.State.Draw:
+				log.Printf("game was a draw!")
+			default:
+				log.Printf("player %d won!", ev.State.State.Winner)
+			}
+		case xoxo.EventOpponentLeft:
+			log.Printf("player left")
+			return nil
+		case xoxo.EventDisconnected:
+			return ev.Err
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/ascii8/xoxo-go/xoxo"
 	"github.com/google/uuid"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/rs/xid"
 	"github.com/rs/zerolog"
@@ -20,7 +21,7 @@ const (
 	windowHeight = 1136
 )
 
-func Run(ctx context.Context, logger zerolog.Logger, debug bool, urlstr, key string) error {
+func Run(ctx context.Context, logger zerolog.Logger, debug bool, mode xoxo.Mode, urlstr, key string) error {
 	ebiten.SetWindowTitle("XOXO")
 	ebiten.SetScreenClearedEveryFrame(true)
 	ebiten.SetWindowClosingHandled(true)
@@ -39,7 +40,7 @@ func Run(ctx context.Context, logger zerolog.Logger, debug bool, urlstr, key str
 		Int("height", height).
 		Msg("window")
 	ebiten.SetWindowSize(int(windowWidth*scaling), int(windowHeight*scaling))
-	game = New(ctx, logger, debug, scaling, urlstr, key)
+	game = New(ctx, logger, debug, scaling, mode, urlstr, key)
 	if err := ebiten.RunGame(game); err != nil && !errors.Is(err, ebiten.Termination) {
 		return err
 	}
@@ -57,29 +58,37 @@ type Game struct {
 	logger   zerolog.Logger
 	debug    bool
 	scaling  float64
+	mode     xoxo.Mode
 	url      string
 	key      string
 	userId   string
 	username string
 	exiting  bool
 	err      error
+	sess     xoxo.Session
 	cl       *xoxo.Client
 	join     *Button
 	leave    *Button
+	private  *Button
+	code     string
+	codes    chan string
+	input    []rune
 	board    *Board
 	tick     int
 }
 
-func New(ctx context.Context, logger zerolog.Logger, debug bool, scaling float64, urlstr, key string) *Game {
+func New(ctx context.Context, logger zerolog.Logger, debug bool, scaling float64, mode xoxo.Mode, urlstr, key string) *Game {
 	return &Game{
 		ctx:      ctx,
 		logger:   logger,
 		debug:    debug,
 		scaling:  scaling,
+		mode:     mode,
 		url:      urlstr,
 		key:      key,
 		userId:   uuid.New().String(),
 		username: xid.New().String(),
+		codes:    make(chan string, 1),
 		tick:     -1,
 	}
 }
@@ -88,18 +97,34 @@ func (g *Game) init() error {
 	if err := assets.Init(windowWidth, windowHeight); err != nil {
 		return err
 	}
-	g.cl = xoxo.NewClient(
-		xoxo.WithURL(g.url),
-		xoxo.WithServerKey(g.key),
-		xoxo.WithUserId(g.userId),
-		xoxo.WithUsername(g.username),
-		xoxo.WithLogf(func(s string, v ...interface{}) {
-			g.logger.Debug().CallerSkipFrame(1).Msgf(s, v...)
-		}),
-		xoxo.WithDebug(),
-		xoxo.WithPersist(),
-		xoxo.WithHandler(g),
-	)
+	logf := func(s string, v ...interface{}) {
+		g.logger.Debug().CallerSkipFrame(1).Msgf(s, v...)
+	}
+	switch g.mode {
+	case xoxo.ModeOnline:
+		g.cl = xoxo.NewClient(
+			xoxo.WithURL(g.url),
+			xoxo.WithServerKey(g.key),
+			xoxo.WithUserId(g.userId),
+			xoxo.WithUsername(g.username),
+			xoxo.WithLogf(logf),
+			xoxo.WithDebug(),
+			xoxo.WithPersist(),
+			xoxo.WithHandler(g),
+		)
+		g.sess = g.cl
+	default:
+		sess, err := xoxo.NewLocal(
+			g.mode,
+			xoxo.WithLocalUsername(g.username),
+			xoxo.WithLocalLogf(logf),
+			xoxo.WithLocalHandler(g),
+		)
+		if err != nil {
+			return err
+		}
+		g.sess = sess
+	}
 	g.join = NewButton(
 		"Join",
 		100, 800,
@@ -107,6 +132,13 @@ func (g *Game) init() error {
 		color.White, color.RGBA{255, 0, 127, 255},
 		assets.Btn, assets.BtnActive,
 	)
+	g.private = NewButton(
+		"Private",
+		100, 930,
+		414, 108,
+		color.White, color.RGBA{255, 0, 127, 255},
+		assets.Btn, assets.BtnActive,
+	)
 	g.leave = NewButton(
 		"Leave",
 		100, 800,
@@ -114,7 +146,7 @@ func (g *Game) init() error {
 		color.White, color.RGBA{255, 0, 127, 255},
 		assets.Btn, assets.BtnActive,
 	)
-	go g.cl.Open(g.ctx)
+	go g.sess.Open(g.ctx)
 	return nil
 }
 
@@ -126,7 +158,15 @@ func (g *Game) Update() error {
 	case ebiten.IsWindowBeingClosed():
 		g.Shutdown()
 		return nil
-	case g.cl != nil:
+	case g.sess != nil:
+		// created private match codes are received from the client's goroutine
+		select {
+		case g.code = <-g.codes:
+		default:
+		}
+		if g.Connected() && g.sess.State() == nil {
+			g.handleLobby()
+		}
 		return nil
 	}
 	if err := g.init(); err != nil {
@@ -135,21 +175,75 @@ func (g *Game) Update() error {
 	return nil
 }
 
+// handleLobby handles the join and private buttons, and the typed join code.
+func (g *Game) handleLobby() {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		x, y := ebiten.CursorPosition()
+		switch {
+		case g.join.In(x, y):
+			g.code = ""
+			g.sess.JoinAsync(g.ctx, func(err error) {
+				if err != nil {
+					g.logger.Debug().Err(err).Msg("unable to join")
+				}
+			})
+		case g.private.In(x, y) && g.cl != nil:
+			g.cl.CreatePrivateAsync(g.ctx, xoxo.DefaultVariant, 0, false, func(code string, err error) {
+				if err != nil {
+					g.logger.Debug().Err(err).Msg("unable to create private match")
+					return
+				}
+				select {
+				case g.codes <- code:
+				default:
+				}
+			})
+		}
+	}
+	g.input = ebiten.AppendInputChars(g.input)
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(g.input) != 0 {
+		g.input = g.input[:len(g.input)-1]
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) && len(g.input) != 0 && g.cl != nil {
+		code := string(g.input)
+		g.code, g.input = "", nil
+		g.cl.JoinByCodeAsync(g.ctx, code, func(err error) {
+			if err != nil {
+				g.logger.Debug().Err(err).Str("code", code).Msg("unable to join by code")
+			}
+		})
+	}
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	// background
 	screen.DrawImage(assets.Bg, assets.BgOpts)
 	connected := g.Connected()
 	x, y := ebiten.CursorPosition()
-	switch state := g.cl.State(); {
+	switch state := g.sess.State(); {
 	case connected && state == nil:
 		// draw title
 		// logo
 		// empty board + TIC TAC TOE
 		g.join.Draw(screen, x, y, g.tick)
+		if g.cl != nil {
+			g.private.Draw(screen, x, y, g.tick)
+		}
+		switch {
+		case g.code != "":
+			text.Draw(screen, "Code: "+g.code, assets.Din24, 100, 770, color.White)
+		case len(g.input) != 0:
+			text.Draw(screen, "Join code: "+string(g.input), assets.Din24, 100, 770, color.White)
+		}
 	case connected:
 		// draw board/match
 	}
-	if connected {
+	switch {
+	case connected && g.mode == xoxo.ModeHotSeat:
+		text.Draw(screen, "Offline: hot seat.", assets.Din24, 16, windowHeight-72, color.White)
+	case connected && g.mode == xoxo.ModeAI:
+		text.Draw(screen, "Offline: vs AI.", assets.Din24, 16, windowHeight-72, color.White)
+	case connected:
 		text.Draw(screen, "Connected.", assets.Din24, 16, windowHeight-72, color.White)
 	}
 	if g.debug {
@@ -170,8 +264,8 @@ func (g *Game) ShutdownWithError(err error) {
 }
 
 func (g *Game) Connected() bool {
-	cl := g.cl
-	return cl != nil && cl.Connected()
+	sess := g.sess
+	return sess != nil && sess.Connected()
 }
 
 func (g *Game) Err() error {
@@ -184,10 +278,10 @@ func (g *Game) Layout(int, int) (int, int) {
 
 func (g *Game) Shutdown() {
 	g.logger.Debug().Msg("Shutdown")
-	cl := g.cl
+	sess := g.sess
 	g.exiting = true
-	if cl != nil {
-		cl.Leave(context.Background())
+	if sess != nil {
+		sess.Leave(context.Background())
 	}
 }
 
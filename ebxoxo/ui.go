@@ -38,6 +38,11 @@ func NewButton(label string, x, y, w, h int, color, colorActive color.Color, img
 	}
 }
 
+// In returns true when x, y is within the button.
+func (b *Button) In(x, y int) bool {
+	return b.x <= x && x < b.x+b.w && b.y <= y && y < b.y+b.h
+}
+
 func (b *Button) Draw(screen *ebiten.Image, x, y, tick int) {
 	screen.DrawImage(b.img, b.imgOpts)
 }
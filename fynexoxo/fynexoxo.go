@@ -19,8 +19,11 @@ import (
 
 var game *Game
 
-func Run(ctx context.Context, logger zerolog.Logger, debug bool, urlstr, key string) error {
-	game = New(ctx, logger, debug, urlstr, key)
+func Run(ctx context.Context, logger zerolog.Logger, debug bool, mode xoxo.Mode, urlstr, key string) error {
+	var err error
+	if game, err = New(ctx, logger, debug, mode, urlstr, key); err != nil {
+		return err
+	}
 	return game.Run()
 }
 
@@ -32,42 +35,61 @@ type Game struct {
 	ctx            context.Context
 	logger         zerolog.Logger
 	debug          bool
+	mode           xoxo.Mode
 	url            string
 	key            string
 	userId         string
 	username       string
+	sess           xoxo.Session
 	cl             *xoxo.Client
 	app            fyne.App
 	window         fyne.Window
 	connectedLabel *widget.Label
 	turnLabel      *widget.Label
+	codeEntry      *widget.Entry
+	code           string
+	grid           *fyne.Container
+	variant        xoxo.Variant
 	cellButtons    []*widget.Button
 }
 
-func New(ctx context.Context, logger zerolog.Logger, debug bool, urlstr, key string) *Game {
+func New(ctx context.Context, logger zerolog.Logger, debug bool, mode xoxo.Mode, urlstr, key string) (*Game, error) {
 	g := &Game{
 		ctx:      ctx,
 		logger:   logger,
 		debug:    debug,
+		mode:     mode,
 		url:      urlstr,
 		key:      key,
 		userId:   uuid.New().String(),
 		username: xid.New().String(),
 	}
 	g.init()
+	logf := func(s string, v ...interface{}) {
+		g.logger.Debug().CallerSkipFrame(1).Msgf(s, v...)
+	}
+	if mode != xoxo.ModeOnline {
+		var err error
+		g.sess, err = xoxo.NewLocal(
+			mode,
+			xoxo.WithLocalUsername(g.username),
+			xoxo.WithLocalLogf(logf),
+			xoxo.WithLocalHandler(g),
+		)
+		return g, err
+	}
 	g.cl = xoxo.NewClient(
 		xoxo.WithURL(g.url),
 		xoxo.WithServerKey(g.key),
 		xoxo.WithUserId(g.userId),
 		xoxo.WithUsername(g.username),
-		xoxo.WithLogf(func(s string, v ...interface{}) {
-			g.logger.Debug().CallerSkipFrame(1).Msgf(s, v...)
-		}),
+		xoxo.WithLogf(logf),
 		xoxo.WithDebug(),
 		xoxo.WithPersist(),
 		xoxo.WithHandler(g),
 	)
-	return g
+	g.sess = g.cl
+	return g, nil
 }
 
 func (g *Game) init() {
@@ -75,19 +97,31 @@ func (g *Game) init() {
 	g.window = g.app.NewWindow("XOXO")
 	g.connectedLabel = widget.NewLabel("...")
 	g.turnLabel = widget.NewLabel("")
-	g.cellButtons = make([]*widget.Button, 9)
-	grid := container.New(layout.NewGridLayoutWithColumns(3))
-	for i := 0; i < 9; i++ {
-		g.cellButtons[i] = widget.NewButton(" ", g.move(i/3, i%3))
-		grid.Add(g.cellButtons[i])
-	}
+	g.grid = container.New(layout.NewGridLayoutWithColumns(xoxo.DefaultVariant.Cols))
+	g.layoutCells(xoxo.DefaultVariant)
+	g.codeEntry = widget.NewEntry()
+	g.codeEntry.SetPlaceHolder("Code")
 	top := container.NewHBox(widget.NewLabel("XOXO"), g.turnLabel)
+	bottom := container.NewVBox(
+		widget.NewButton("Join", g.join),
+		container.NewGridWithColumns(
+			2,
+			widget.NewButton("Rematch", g.rematch),
+			widget.NewButton("Leave", g.leave),
+		),
+		container.NewGridWithColumns(
+			3,
+			widget.NewButton("Private", g.createPrivate),
+			g.codeEntry,
+			widget.NewButton("Join Code", g.joinByCode),
+		),
+	)
 	content := container.NewBorder(
 		top,
-		widget.NewButton("Join", g.join),
+		bottom,
 		nil,
 		nil,
-		grid,
+		g.grid,
 	)
 	g.window.SetContent(container.NewBorder(
 		nil,
@@ -100,12 +134,25 @@ func (g *Game) init() {
 	g.window.SetFixedSize(true)
 }
 
+// layoutCells lays out the cell buttons for the variant.
+func (g *Game) layoutCells(variant xoxo.Variant) {
+	g.variant = variant
+	g.cellButtons = make([]*widget.Button, variant.Rows*variant.Cols)
+	g.grid.Layout = layout.NewGridLayoutWithColumns(variant.Cols)
+	g.grid.RemoveAll()
+	for i := 0; i < len(g.cellButtons); i++ {
+		g.cellButtons[i] = widget.NewButton(" ", g.move(i/variant.Cols, i%variant.Cols))
+		g.grid.Add(g.cellButtons[i])
+	}
+}
+
 func (g *Game) join() {
 	g.logger.
 		Debug().
 		Msg("join")
-	if g.cl.Connected() {
-		if err := g.cl.Join(g.ctx); err != nil {
+	g.code = ""
+	if g.sess.Connected() {
+		if err := g.sess.Join(g.ctx); err != nil {
 			g.logger.
 				Debug().
 				Err(err).
@@ -114,6 +161,68 @@ func (g *Game) join() {
 	}
 }
 
+func (g *Game) rematch() {
+	g.logger.
+		Debug().
+		Msg("rematch")
+	if err := g.sess.Rematch(g.ctx, true); err != nil {
+		g.logger.
+			Debug().
+			Err(err).
+			Msg("unable to rematch")
+	}
+}
+
+func (g *Game) leave() {
+	g.logger.
+		Debug().
+		Msg("leave")
+	g.code = ""
+	if err := g.sess.Leave(g.ctx); err != nil {
+		g.logger.
+			Debug().
+			Err(err).
+			Msg("unable to leave")
+	}
+	g.StateHandler(g.ctx)
+}
+
+func (g *Game) createPrivate() {
+	g.logger.
+		Debug().
+		Msg("create private")
+	// private matches are only available online
+	if g.cl != nil && g.cl.Connected() {
+		code, err := g.cl.CreatePrivate(g.ctx, xoxo.DefaultVariant, 0, false)
+		if err != nil {
+			g.logger.
+				Debug().
+				Err(err).
+				Msg("unable to create private match")
+			return
+		}
+		g.code = code
+		g.StateHandler(g.ctx)
+	}
+}
+
+func (g *Game) joinByCode() {
+	code := g.codeEntry.Text
+	g.logger.
+		Debug().
+		Str("code", code).
+		Msg("join by code")
+	g.code = ""
+	if g.cl != nil && g.cl.Connected() && code != "" {
+		if err := g.cl.JoinByCode(g.ctx, code); err != nil {
+			g.logger.
+				Debug().
+				Err(err).
+				Msg("unable to join by code")
+		}
+	}
+}
+
 func (g *Game) move(row, col int) func() {
 	return func() {
 		g.logger.
@@ -121,7 +230,7 @@ func (g *Game) move(row, col int) func() {
 			Int("row", row).
 			Int("col", col).
 			Msg("move")
-		if err := g.cl.Move(g.ctx, row, col); err != nil {
+		if err := g.sess.Move(g.ctx, row, col); err != nil {
 			g.logger.
 				Debug().
 				Err(err).
@@ -131,7 +240,7 @@ func (g *Game) move(row, col int) func() {
 }
 
 func (g *Game) Run() error {
-	if err := g.cl.Open(g.ctx); err != nil {
+	if err := g.sess.Open(g.ctx); err != nil {
 		return err
 	}
 	g.window.ShowAndRun()
@@ -147,7 +256,14 @@ func (g *Game) Shutdown() {
 }
 
 func (g *Game) ConnectHandler(ctx context.Context) {
-	g.connectedLabel.SetText("Connected.")
+	switch g.mode {
+	case xoxo.ModeHotSeat:
+		g.connectedLabel.SetText("Offline: hot seat.")
+	case xoxo.ModeAI:
+		g.connectedLabel.SetText("Offline: vs AI.")
+	default:
+		g.connectedLabel.SetText("Connected.")
+	}
 	g.turnLabel.SetText("")
 }
 
@@ -167,31 +283,53 @@ func (g *Game) StateHandler(ctx context.Context) {
 	g.logger.
 		Debug().
 		Msg("state change")
-	state := g.cl.State()
-	s := ""
+	state := g.sess.State()
+	s, rematch := "", ""
+	if state != nil && state.State.RematchCountdown != 0 {
+		rematch = fmt.Sprintf(" Rematch? %d...", state.State.RematchCountdown)
+	}
 	switch {
+	case state == nil && g.code != "":
+		s = "Code: " + g.code
 	case state == nil:
 	case state.State.Winner != 0:
 		winner := 'O'
 		if state.State.Winner == 2 {
 			winner = 'X'
 		}
-		s = fmt.Sprintf("Player %d (%c) wins! %d...", state.State.Winner, winner, state.State.RematchCountdown)
+		reason := ""
+		switch state.State.Reason {
+		case xoxo.ReasonTimeout:
+			reason = " on time"
+		case xoxo.ReasonResigned:
+			reason = " by resignation"
+		}
+		s = fmt.Sprintf("Player %d (%c) wins%s!%s", state.State.Winner, winner, reason, rematch)
+	case state.State.Draw && state.State.Reason == xoxo.ReasonAgreed:
+		s = "Draw agreed!" + rematch
 	case state.State.Draw:
-		s = fmt.Sprintf("Draw! %d...", state.State.RematchCountdown)
+		s = "Draw!" + rematch
 	case !state.YourTurn:
 		s = "Waiting Other Player"
+	case g.mode == xoxo.ModeHotSeat:
+		s = fmt.Sprintf("Player %d (%c) To Move!", state.State.PlayerTurn, "OX"[state.State.PlayerTurn-1])
 	case state.YourTurn:
 		s = "Your Turn!"
 	}
+	if state != nil && state.State.Series != nil && state.State.Series.Games != 0 {
+		s += " " + state.State.Series.String()
+	}
 	g.turnLabel.SetText(s)
-	for i := 0; i < 9; i++ {
+	if state != nil && state.State.Variant != g.variant {
+		g.layoutCells(state.State.Variant)
+	}
+	for i, cols := 0, g.variant.Cols; i < len(g.cellButtons); i++ {
 		s := ""
 		switch {
 		case state == nil:
-		case state.State.Cells[i/3][i%3] == 1:
+		case state.State.Cells[i/cols][i%cols] == 1:
 			s = "O"
-		case state.State.Cells[i/3][i%3] == 2:
+		case state.State.Cells[i/cols][i%cols] == 2:
 			s = "X"
 		}
 		g.cellButtons[i].SetText(s)
@@ -25,8 +25,11 @@ const windowWidth, windowHeight = 640, 900
 
 var game *Game
 
-func Run(ctx context.Context, logger zerolog.Logger, debug bool, urlstr, key string) error {
-	game = New(ctx, logger, debug, urlstr, key)
+func Run(ctx context.Context, logger zerolog.Logger, debug bool, mode xoxo.Mode, urlstr, key string) error {
+	var err error
+	if game, err = New(ctx, logger, debug, mode, urlstr, key); err != nil {
+		return err
+	}
 	return game.Run()
 }
 
@@ -35,48 +38,70 @@ func Shutdown() {
 }
 
 type Game struct {
-	ctx              context.Context
-	logger           zerolog.Logger
-	debug            bool
-	url              string
-	key              string
-	userId           string
-	username         string
-	cl               *xoxo.Client
-	window           *app.Window
-	connectedLabel   string
-	turnLabel        string
-	cellButtonLabels []string
-	join             *widget.Clickable
-	cellButtons      []*widget.Clickable
+	ctx            context.Context
+	logger         zerolog.Logger
+	debug          bool
+	mode           xoxo.Mode
+	url            string
+	key            string
+	userId         string
+	username       string
+	sess           xoxo.Session
+	cl             *xoxo.Client
+	window         *app.Window
+	connectedLabel string
+	turnLabel      string
+	variant        xoxo.Variant
+	variants       chan xoxo.Variant
+	join           *widget.Clickable
+	rematch        *widget.Clickable
+	leave          *widget.Clickable
+	private        *widget.Clickable
+	joinCode       *widget.Clickable
+	codeEditor     *widget.Editor
+	code           string
+	cellButtons    []*widget.Clickable
 }
 
-func New(ctx context.Context, logger zerolog.Logger, debug bool, urlstr, key string) *Game {
+func New(ctx context.Context, logger zerolog.Logger, debug bool, mode xoxo.Mode, urlstr, key string) (*Game, error) {
 	g := &Game{
-		ctx:              ctx,
-		logger:           logger,
-		debug:            debug,
-		url:              urlstr,
-		key:              key,
-		userId:           uuid.New().String(),
-		username:         xid.New().String(),
-		connectedLabel:   ".",
-		cellButtonLabels: make([]string, 9),
+		ctx:            ctx,
+		logger:         logger,
+		debug:          debug,
+		mode:           mode,
+		url:            urlstr,
+		key:            key,
+		userId:         uuid.New().String(),
+		username:       xid.New().String(),
+		connectedLabel: ".",
+		variants:       make(chan xoxo.Variant, 1),
 	}
 	g.init()
+	logf := func(s string, v ...interface{}) {
+		g.logger.Debug().CallerSkipFrame(1).Msgf(s, v...)
+	}
+	if mode != xoxo.ModeOnline {
+		var err error
+		g.sess, err = xoxo.NewLocal(
+			mode,
+			xoxo.WithLocalUsername(g.username),
+			xoxo.WithLocalLogf(logf),
+			xoxo.WithLocalHandler(g),
+		)
+		return g, err
+	}
 	g.cl = xoxo.NewClient(
 		xoxo.WithURL(g.url),
 		xoxo.WithServerKey(g.key),
 		xoxo.WithUserId(g.userId),
 		xoxo.WithUsername(g.username),
-		xoxo.WithLogf(func(s string, v ...interface{}) {
-			g.logger.Debug().CallerSkipFrame(1).Msgf(s, v...)
-		}),
+		xoxo.WithLogf(logf),
 		xoxo.WithDebug(),
 		xoxo.WithPersist(),
 		xoxo.WithHandler(g),
 	)
-	return g
+	g.sess = g.cl
+	return g, nil
 }
 
 func (g *Game) init() {
@@ -89,12 +114,44 @@ func (g *Game) init() {
 		app.Decorated(false),
 	)
 	g.join = new(widget.Clickable)
-	g.cellButtons = make([]*widget.Clickable, 9)
-	for i := 0; i < 9; i++ {
+	g.rematch = new(widget.Clickable)
+	g.leave = new(widget.Clickable)
+	g.private = new(widget.Clickable)
+	g.joinCode = new(widget.Clickable)
+	g.codeEditor = &widget.Editor{
+		SingleLine: true,
+		Submit:     true,
+	}
+	g.layoutCells(xoxo.DefaultVariant)
+}
+
+// layoutCells creates the cell buttons for the variant. Must be called from
+// the UI goroutine.
+func (g *Game) layoutCells(variant xoxo.Variant) {
+	g.variant = variant
+	g.cellButtons = make([]*widget.Clickable, variant.Rows*variant.Cols)
+	for i := 0; i < len(g.cellButtons); i++ {
 		g.cellButtons[i] = new(widget.Clickable)
 	}
 }
 
+// cellLabels returns the labels of the variant's cell buttons for the state.
+func cellLabels(state *xoxo.MatchState, variant xoxo.Variant) []string {
+	labels := make([]string, variant.Rows*variant.Cols)
+	if state == nil || state.State.Variant != variant {
+		return labels
+	}
+	for i, cols := 0, variant.Cols; i < len(labels); i++ {
+		switch state.State.Cells[i/cols][i%cols] {
+		case 1:
+			labels[i] = "O"
+		case 2:
+			labels[i] = "X"
+		}
+	}
+	return labels
+}
+
 func (g *Game) move(row, col int) func() {
 	return func() {
 		g.logger.
@@ -102,7 +159,7 @@ func (g *Game) move(row, col int) func() {
 			Int("row", row).
 			Int("col", col).
 			Msg("move")
-		if err := g.cl.Move(g.ctx, row, col); err != nil {
+		if err := g.sess.Move(g.ctx, row, col); err != nil {
 			g.logger.
 				Debug().
 				Err(err).
@@ -112,7 +169,7 @@ func (g *Game) move(row, col int) func() {
 }
 
 func (g *Game) Run() error {
-	if err := g.cl.Open(g.ctx); err != nil {
+	if err := g.sess.Open(g.ctx); err != nil {
 		return err
 	}
 	go g.run()
@@ -152,9 +209,21 @@ func (g *Game) layout() func(system.FrameEvent) {
 	var grid component.GridState
 	return func(ev system.FrameEvent) {
 		gtx := layout.NewContext(&ops, ev)
+		// the cell buttons are rebuilt for the variant queued by the state
+		// handler
+		select {
+		case variant := <-g.variants:
+			if variant != g.variant {
+				g.layoutCells(variant)
+			}
+		default:
+		}
+		variant, cellButtons := g.variant, g.cellButtons
+		cellButtonLabels := cellLabels(g.sess.State(), variant)
 		// handle join
 		if g.join.Clicked(gtx) {
-			g.cl.JoinAsync(g.ctx, func(err error) {
+			g.code = ""
+			g.sess.JoinAsync(g.ctx, func(err error) {
 				if err != nil {
 					g.logger.
 						Debug().
@@ -163,17 +232,68 @@ func (g *Game) layout() func(system.FrameEvent) {
 				}
 			})
 		}
+		// handle rematch
+		if g.rematch.Clicked(gtx) {
+			go func() {
+				if err := g.sess.Rematch(g.ctx, true); err != nil {
+					g.logger.
+						Debug().
+						Err(err).
+						Msg("unable to rematch")
+				}
+			}()
+		}
+		// handle leave
+		if g.leave.Clicked(gtx) {
+			g.code = ""
+			g.sess.LeaveAsync(g.ctx, func(err error) {
+				if err != nil {
+					g.logger.
+						Debug().
+						Err(err).
+						Msg("unable to leave")
+				}
+				g.StateHandler(g.ctx)
+			})
+		}
+		// handle private, only available online
+		if g.private.Clicked(gtx) && g.cl != nil {
+			g.cl.CreatePrivateAsync(g.ctx, xoxo.DefaultVariant, 0, false, func(code string, err error) {
+				if err != nil {
+					g.logger.
+						Debug().
+						Err(err).
+						Msg("unable to create private match")
+					return
+				}
+				g.code = code
+				g.StateHandler(g.ctx)
+			})
+		}
+		// handle join code
+		if code := strings.TrimSpace(g.codeEditor.Text()); g.joinCode.Clicked(gtx) && code != "" && g.cl != nil {
+			g.code = ""
+			g.cl.JoinByCodeAsync(g.ctx, code, func(err error) {
+				if err != nil {
+					g.logger.
+						Debug().
+						Err(err).
+						Str("code", code).
+						Msg("unable to join by code")
+				}
+			})
+		}
 		// handle cell buttons
-		for i := 0; i < 9; i++ {
-			if g.cellButtons[i].Clicked(gtx) {
-				cell := i
-				g.cl.MoveAsync(g.ctx, cell/3, cell%3, func(err error) {
+		for i := 0; i < len(cellButtons); i++ {
+			if cellButtons[i].Clicked(gtx) {
+				row, col := i/variant.Cols, i%variant.Cols
+				g.sess.MoveAsync(g.ctx, row, col, func(err error) {
 					if err != nil {
 						g.logger.
 							Debug().
 							Err(err).
-							Int("row", cell/3).
-							Int("col", cell%3).
+							Int("row", row).
+							Int("col", col).
 							Msg("unable to move")
 					}
 				})
@@ -195,11 +315,15 @@ func (g *Game) layout() func(system.FrameEvent) {
 			}),
 			// grid
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				size := variant.Cols
+				if size < variant.Rows {
+					size = variant.Rows
+				}
 				return component.Grid(th, &grid).Layout(
 					gtx,
-					3, 3,
+					variant.Rows, variant.Cols,
 					func(_ layout.Axis, _, _ int) int {
-						return (windowWidth - 10) / 3
+						return (windowWidth - 10) / size
 					},
 					func(gtx layout.Context, row, col int) layout.Dimensions {
 						return layout.Inset{
@@ -209,8 +333,8 @@ func (g *Game) layout() func(system.FrameEvent) {
 							gtx,
 							material.Button(
 								th,
-								g.cellButtons[row*3+col],
-								g.cellButtonLabels[row*3+col],
+								cellButtons[row*variant.Cols+col],
+								cellButtonLabels[row*variant.Cols+col],
 							).Layout,
 						)
 					},
@@ -228,6 +352,44 @@ func (g *Game) layout() func(system.FrameEvent) {
 					material.Button(th, g.join, "Join").Layout,
 				)
 			}),
+			// rematch and leave buttons
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{
+					Bottom: 25,
+					Right:  25,
+					Left:   25,
+				}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return layout.Flex{
+						Axis:    layout.Horizontal,
+						Spacing: layout.SpaceBetween,
+					}.Layout(
+						gtx,
+						layout.Rigid(material.Button(th, g.rematch, "Rematch").Layout),
+						layout.Rigid(material.Button(th, g.leave, "Leave").Layout),
+					)
+				})
+			}),
+			// private match
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{
+					Bottom: 25,
+					Right:  25,
+					Left:   25,
+				}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return layout.Flex{
+						Axis:      layout.Horizontal,
+						Spacing:   layout.SpaceBetween,
+						Alignment: layout.Middle,
+					}.Layout(
+						gtx,
+						layout.Rigid(material.Button(th, g.private, "Private").Layout),
+						layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+							return layout.UniformInset(10).Layout(gtx, material.Editor(th, g.codeEditor, "Code").Layout)
+						}),
+						layout.Rigid(material.Button(th, g.joinCode, "Join Code").Layout),
+					)
+				})
+			}),
 			// connected label
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return layout.Inset{
@@ -253,7 +415,14 @@ func (g *Game) Shutdown() {
 }
 
 func (g *Game) ConnectHandler(ctx context.Context) {
-	g.connectedLabel = "Connected."
+	switch g.mode {
+	case xoxo.ModeHotSeat:
+		g.connectedLabel = "Offline: hot seat."
+	case xoxo.ModeAI:
+		g.connectedLabel = "Offline: vs AI."
+	default:
+		g.connectedLabel = "Connected."
+	}
 	g.turnLabel = ""
 	g.window.Invalidate()
 }
@@ -276,34 +445,54 @@ func (g *Game) StateHandler(ctx context.Context) {
 	g.logger.
 		Debug().
 		Msg("state change")
-	state := g.cl.State()
-	s := ""
+	state := g.sess.State()
+	s, rematch := "", ""
+	if state != nil && state.State.RematchCountdown != 0 {
+		rematch = fmt.Sprintf(" Rematch? %d...", state.State.RematchCountdown)
+	}
 	switch {
+	case state == nil && g.code != "":
+		s = "Code: " + g.code
 	case state == nil:
 	case state.State.Winner != 0:
 		winner := 'O'
 		if state.State.Winner == 2 {
 			winner = 'X'
 		}
-		s = fmt.Sprintf("Player %d (%c) wins! %d...", state.State.Winner, winner, state.State.RematchCountdown)
+		reason := ""
+		switch state.State.Reason {
+		case xoxo.ReasonTimeout:
+			reason = " on time"
+		case xoxo.ReasonResigned:
+			reason = " by resignation"
+		}
+		s = fmt.Sprintf("Player %d (%c) wins%s!%s", state.State.Winner, winner, reason, rematch)
+	case state.State.Draw && state.State.Reason == xoxo.ReasonAgreed:
+		s = "Draw agreed!" + rematch
 	case state.State.Draw:
-		s = fmt.Sprintf("Draw! %d...", state.State.RematchCountdown)
+		s = "Draw!" + rematch
 	case !state.YourTurn:
 		s = "Waiting Other Player"
+	case g.mode == xoxo.ModeHotSeat:
+		s = fmt.Sprintf("Player %d (%c) To Move!", state.State.PlayerTurn, "OX"[state.State.PlayerTurn-1])
 	case state.YourTurn:
 		s = "Your Turn!"
 	}
+	if state != nil && state.State.Series != nil && state.State.Series.Games != 0 {
+		s += " " + state.State.Series.String()
+	}
 	g.turnLabel = s
-	for i := 0; i < 9; i++ {
-		s := ""
-		switch {
-		case state == nil:
-		case state.State.Cells[i/3][i%3] == 1:
-			s = "O"
-		case state.State.Cells[i/3][i%3] == 2:
-			s = "X"
+	// the cell buttons are rebuilt on the UI goroutine, replacing any variant
+	// not yet taken
+	if state != nil {
+		select {
+		case <-g.variants:
+		default:
+		}
+		select {
+		case g.variants <- state.State.Variant:
+		default:
 		}
-		g.cellButtonLabels[i] = s
 	}
 	g.window.Invalidate()
 }
@@ -0,0 +1,76 @@
+package nkxoxo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ascii8/xoxo-go/solver"
+	"github.com/ascii8/xoxo-go/xoxo"
+	"github.com/google/uuid"
+)
+
+// bot is a server side bot opponent.
+type bot struct {
+	level    xoxo.BotLevel
+	userId   string
+	username string
+	strategy xoxo.Strategy
+	// fallback is used when the strategy is unable to choose a move in time.
+	fallback xoxo.Strategy
+}
+
+// newBot creates a new bot for the level and variant.
+func newBot(level xoxo.BotLevel, variant xoxo.Variant) *bot {
+	seed := time.Now().UnixNano()
+	b := &bot{
+		level:    level,
+		userId:   uuid.New().String(),
+		username: fmt.Sprintf("%s bot", level),
+		fallback: xoxo.NewRandom(rand.New(rand.NewSource(seed))),
+	}
+	r := rand.New(rand.NewSource(seed + 1))
+	switch level {
+	case xoxo.BotMedium:
+		// only sees immediate wins and blocks
+		b.strategy = solver.New(solver.WithMaxDepth(2), solver.WithRand(r))
+	case xoxo.BotHard:
+		opts := []solver.Option{solver.WithRand(r)}
+		if maxSolveCells < variant.Rows*variant.Cols {
+			opts = append(opts, solver.WithMaxDepth(4))
+		}
+		b.strategy = solver.New(opts...)
+	default:
+		b.strategy = b.fallback
+	}
+	return b
+}
+
+// player returns the bot's player number in the state.
+func (b *bot) player(state *xoxo.State) int {
+	for i, p := range state.Players {
+		if p.UserId == b.userId {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// move chooses the bot's next move.
+func (b *bot) move(ctx context.Context, state *xoxo.State) (xoxo.Move, error) {
+	ctx, cancel := context.WithTimeout(ctx, botTimeout)
+	defer cancel()
+	move, err := b.strategy.ChooseMove(ctx, state)
+	if err != nil {
+		// fallback to a random move when unable to solve in time
+		return b.fallback.ChooseMove(ctx, state)
+	}
+	return move, nil
+}
+
+// botTimeout is the maximum time a bot spends choosing a move.
+const botTimeout = 500 * time.Millisecond
+
+// maxSolveCells is the maximum number of cells a hard bot fully solves.
+const maxSolveCells = 16
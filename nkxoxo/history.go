@@ -0,0 +1,156 @@
+package nkxoxo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// historyCollection is the storage collection of the match records.
+const historyCollection = "history"
+
+// recordGame adds the ended game to the match record, and writes the match
+// record for each player.
+func recordGame(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, s *matchState) error {
+	if s.history.MatchId == "" {
+		s.history.MatchId, _ = ctx.Value(runtime.RUNTIME_CTX_MATCH_ID).(string)
+	}
+	s.history.Games = append(s.history.Games, xoxo.GameRecord{
+		Variant: s.variant,
+		Players: publicPlayers(s.state.Players),
+		First:   s.first,
+		Moves:   s.moves,
+		Winner:  s.state.Winner,
+		Draw:    s.state.Draw,
+		Reason:  s.state.Reason,
+	})
+	buf, err := json.Marshal(s.history)
+	if err != nil {
+		return fmt.Errorf("unable to marshal match record: %w", err)
+	}
+	var writes []*runtime.StorageWrite
+	for _, p := range s.state.Players {
+		if p.Bot {
+			continue
+		}
+		writes = append(writes, &runtime.StorageWrite{
+			Collection:      historyCollection,
+			Key:             s.history.MatchId,
+			UserID:          p.UserId,
+			Value:           string(buf),
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		})
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	if _, err := nk.StorageWrite(ctx, writes); err != nil {
+		return fmt.Errorf("unable to write match record: %w", err)
+	}
+	logger.
+		WithField("match_id", s.history.MatchId).
+		WithField("games", len(s.history.Games)).
+		Debug("recorded game")
+	return nil
+}
+
+// publicPlayers returns the players without their session details.
+func publicPlayers(players []xoxo.Player) []xoxo.Player {
+	v := make([]xoxo.Player, len(players))
+	for i, p := range players {
+		p.Node, p.SessionId = "", ""
+		v[i] = p
+	}
+	return v
+}
+
+// rpcHistory lists the calling user's match records, without their moves.
+func rpcHistory(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userId, _ := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if userId == "" {
+		return "", runtime.NewError("no user id", 3)
+	}
+	var req xoxo.HistoryRequest
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", runtime.NewError("invalid request", 3)
+		}
+	}
+	switch {
+	case req.Limit == 0:
+		req.Limit = 10
+	case req.Limit < 0 || req.Limit > 100:
+		return "", runtime.NewError("invalid limit", 3)
+	}
+	objs, cursor, err := nk.StorageList(ctx, userId, userId, historyCollection, req.Limit, req.Cursor)
+	if err != nil {
+		logger.
+			WithField("error", err).
+			Error("unable to list match records")
+		return "", runtime.NewError("unable to list match records", 13)
+	}
+	res := xoxo.HistoryResponse{
+		Matches: make([]xoxo.MatchRecord, len(objs)),
+		Cursor:  cursor,
+	}
+	for i, obj := range objs {
+		if err := json.Unmarshal([]byte(obj.GetValue()), &res.Matches[i]); err != nil {
+			logger.
+				WithField("key", obj.GetKey()).
+				WithField("error", err).
+				Error("unable to unmarshal match record")
+			return "", runtime.NewError("unable to list match records", 13)
+		}
+		for j := range res.Matches[i].Games {
+			res.Matches[i].Games[j].Moves = nil
+		}
+	}
+	buf, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// rpcReplay retrieves the calling user's match record for the match.
+func rpcReplay(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userId, _ := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if userId == "" {
+		return "", runtime.NewError("no user id", 3)
+	}
+	var req xoxo.ReplayRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil || req.MatchId == "" {
+		return "", runtime.NewError("invalid request", 3)
+	}
+	objs, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: historyCollection,
+		Key:        req.MatchId,
+		UserID:     userId,
+	}})
+	switch {
+	case err != nil:
+		logger.
+			WithField("error", err).
+			Error("unable to read match record")
+		return "", runtime.NewError("unable to read match record", 13)
+	case len(objs) == 0:
+		return "", runtime.NewError("match record not found", 5)
+	}
+	var res xoxo.ReplayResponse
+	if err := json.Unmarshal([]byte(objs[0].GetValue()), &res.Match); err != nil {
+		logger.
+			WithField("error", err).
+			Error("unable to unmarshal match record")
+		return "", runtime.NewError("unable to read match record", 13)
+	}
+	buf, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,289 @@
+// Package matchtest provides an in-memory Nakama match runtime, for testing
+// authoritative match handlers without a Nakama server.
+package matchtest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// Presence is a match presence.
+type Presence struct {
+	UserId    string
+	SessionId string
+	Username  string
+	Node      string
+}
+
+// NewPresence creates a presence for the user, with a session id derived
+// from the user id.
+func NewPresence(userId string) *Presence {
+	return &Presence{
+		UserId:    userId,
+		SessionId: userId + "-session",
+		Username:  userId,
+		Node:      "matchtest",
+	}
+}
+
+func (p *Presence) GetHidden() bool                   { return false }
+func (p *Presence) GetPersistence() bool              { return false }
+func (p *Presence) GetUsername() string               { return p.Username }
+func (p *Presence) GetStatus() string                 { return "" }
+func (p *Presence) GetReason() runtime.PresenceReason { return runtime.PresenceReasonUnknown }
+func (p *Presence) GetUserId() string                 { return p.UserId }
+func (p *Presence) GetSessionId() string              { return p.SessionId }
+func (p *Presence) GetNodeId() string                 { return p.Node }
+
+// MatchmakerEntry is a matched matchmaker ticket.
+type MatchmakerEntry struct {
+	Presence   *Presence
+	Ticket     string
+	Properties map[string]interface{}
+}
+
+// NewMatchmakerEntry creates a matchmaker entry for the user, with the
+// ticket's string and numeric properties.
+func NewMatchmakerEntry(userId string, properties map[string]interface{}) *MatchmakerEntry {
+	return &MatchmakerEntry{
+		Presence:   NewPresence(userId),
+		Ticket:     userId + "-ticket",
+		Properties: properties,
+	}
+}
+
+func (e *MatchmakerEntry) GetPresence() runtime.Presence         { return e.Presence }
+func (e *MatchmakerEntry) GetTicket() string                     { return e.Ticket }
+func (e *MatchmakerEntry) GetProperties() map[string]interface{} { return e.Properties }
+func (e *MatchmakerEntry) GetPartyId() string                    { return "" }
+
+// Data is a message sent to the match by a presence.
+type Data struct {
+	*Presence
+	OpCode      int64
+	Data        []byte
+	ReceiveTime int64
+}
+
+func (d *Data) GetOpCode() int64      { return d.OpCode }
+func (d *Data) GetData() []byte       { return d.Data }
+func (d *Data) GetReliable() bool     { return true }
+func (d *Data) GetReceiveTime() int64 { return d.ReceiveTime }
+
+// Message is a message broadcast by the match.
+type Message struct {
+	Tick   int64
+	OpCode int64
+	Data   []byte
+	// Presences are the recipients, or nil when sent to all presences.
+	Presences []runtime.Presence
+}
+
+// To returns true when the message was sent to the session.
+func (m Message) To(sessionId string) bool {
+	if m.Presences == nil {
+		return true
+	}
+	for _, p := range m.Presences {
+		if p.GetSessionId() == sessionId {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher is a match dispatcher recording the broadcast messages.
+type Dispatcher struct {
+	h *Harness
+	// Messages are the broadcast messages, in order.
+	Messages []Message
+	// Kicked are the kicked presences.
+	Kicked []runtime.Presence
+	// Label is the match label.
+	Label string
+}
+
+func (d *Dispatcher) BroadcastMessage(opCode int64, data []byte, presences []runtime.Presence, sender runtime.Presence, reliable bool) error {
+	d.Messages = append(d.Messages, Message{
+		Tick:      d.h.Tick,
+		OpCode:    opCode,
+		Data:      append([]byte(nil), data...),
+		Presences: append([]runtime.Presence(nil), presences...),
+	})
+	return nil
+}
+
+func (d *Dispatcher) BroadcastMessageDeferred(opCode int64, data []byte, presences []runtime.Presence, sender runtime.Presence, reliable bool) error {
+	return d.BroadcastMessage(opCode, data, presences, sender, reliable)
+}
+
+func (d *Dispatcher) MatchKick(presences []runtime.Presence) error {
+	d.Kicked = append(d.Kicked, presences...)
+	return nil
+}
+
+func (d *Dispatcher) MatchLabelUpdate(label string) error {
+	d.Label = label
+	return nil
+}
+
+// Harness drives a match, calling its handlers as the Nakama match runtime
+// does. Ticks are only run when requested, making the match deterministic.
+type Harness struct {
+	Match      runtime.Match
+	Ctx        context.Context
+	Logger     runtime.Logger
+	NK         *NakamaModule
+	Dispatcher *Dispatcher
+	// Tick is the next tick to run.
+	Tick int64
+	// TickRate is the match's tick rate.
+	TickRate int
+	// State is the match state returned by the last handler call.
+	State interface{}
+	// Done is set when the match has terminated.
+	Done bool
+
+	queue []runtime.MatchData
+}
+
+// Option is a harness option.
+type Option func(*Harness)
+
+// WithLogf is a harness option to log the match's messages to logf.
+func WithLogf(logf func(string, ...interface{})) Option {
+	return func(h *Harness) {
+		h.Logger = NewLogger(logf)
+	}
+}
+
+// WithNakamaModule is a harness option to set the Nakama module.
+func WithNakamaModule(nk *NakamaModule) Option {
+	return func(h *Harness) {
+		h.NK = nk
+	}
+}
+
+// New creates a harness for the match, initializing it with the params.
+func New(ctx context.Context, matchId string, match runtime.Match, params map[string]interface{}, opts ...Option) (*Harness, error) {
+	h := &Harness{
+		Match:  match,
+		Ctx:    context.WithValue(ctx, runtime.RUNTIME_CTX_MATCH_ID, matchId),
+		Logger: NewLogger(nil),
+		NK:     NewNakamaModule(),
+		Tick:   1,
+	}
+	h.Dispatcher = &Dispatcher{h: h}
+	for _, o := range opts {
+		o(h)
+	}
+	var label string
+	h.State, h.TickRate, label = match.MatchInit(h.Ctx, h.Logger, nil, h.NK, params)
+	if h.State == nil {
+		return nil, fmt.Errorf("match init returned no state")
+	}
+	h.Dispatcher.Label = label
+	h.NK.mu.Lock()
+	defer h.NK.mu.Unlock()
+	h.NK.Matches[matchId] = h
+	return h, nil
+}
+
+// Join attempts to join the presence to the match, returning the rejection
+// reason when the join is not accepted.
+func (h *Harness) Join(p *Presence, metadata map[string]string) error {
+	if h.Done {
+		return fmt.Errorf("match terminated")
+	}
+	state, ok, reason := h.Match.MatchJoinAttempt(h.Ctx, h.Logger, nil, h.NK, h.Dispatcher, h.Tick, h.State, p, metadata)
+	if h.State = state; !ok {
+		return fmt.Errorf("join rejected: %s", reason)
+	}
+	h.update(h.Match.MatchJoin(h.Ctx, h.Logger, nil, h.NK, h.Dispatcher, h.Tick, h.State, []runtime.Presence{p}))
+	return nil
+}
+
+// Leave removes the presences from the match.
+func (h *Harness) Leave(presences ...*Presence) {
+	if h.Done {
+		return
+	}
+	v := make([]runtime.Presence, len(presences))
+	for i, p := range presences {
+		v[i] = p
+	}
+	h.update(h.Match.MatchLeave(h.Ctx, h.Logger, nil, h.NK, h.Dispatcher, h.Tick, h.State, v))
+}
+
+// Send queues a message from the presence, delivered on the next tick.
+func (h *Harness) Send(p *Presence, opCode int64, data []byte) {
+	h.queue = append(h.queue, &Data{
+		Presence:    p,
+		OpCode:      opCode,
+		Data:        data,
+		ReceiveTime: h.Tick,
+	})
+}
+
+// Step runs a tick, delivering the queued messages. Returns false when the
+// match has terminated.
+func (h *Harness) Step() bool {
+	if h.Done {
+		return false
+	}
+	messages := h.queue
+	h.queue = nil
+	h.update(h.Match.MatchLoop(h.Ctx, h.Logger, nil, h.NK, h.Dispatcher, h.Tick, h.State, messages))
+	h.Tick++
+	return !h.Done
+}
+
+// Run runs up to n ticks, returning false when the match has terminated.
+func (h *Harness) Run(n int) bool {
+	for i := 0; i < n; i++ {
+		if !h.Step() {
+			return false
+		}
+	}
+	return !h.Done
+}
+
+// Terminate terminates the match, as when the server shuts down.
+func (h *Harness) Terminate(graceSeconds int) {
+	if h.Done {
+		return
+	}
+	h.update(h.Match.MatchTerminate(h.Ctx, h.Logger, nil, h.NK, h.Dispatcher, h.Tick, h.State, graceSeconds))
+	h.Done = true
+}
+
+// update sets the match state returned by a handler, terminating the match
+// when the state is nil.
+func (h *Harness) update(state interface{}) {
+	if h.State = state; state == nil {
+		h.Done = true
+	}
+}
+
+// Received returns the messages sent to the presence, with the op code, or all
+// messages sent to the presence when opCode is 0.
+func (h *Harness) Received(p *Presence, opCode int64) []Message {
+	var v []Message
+	for _, m := range h.Dispatcher.Messages {
+		if m.To(p.SessionId) && (opCode == 0 || m.OpCode == opCode) {
+			v = append(v, m)
+		}
+	}
+	return v
+}
+
+// Last returns the last message sent to the presence with the op code.
+func (h *Harness) Last(p *Presence, opCode int64) (Message, bool) {
+	v := h.Received(p, opCode)
+	if len(v) == 0 {
+		return Message{}, false
+	}
+	return v[len(v)-1], true
+}
@@ -0,0 +1,274 @@
+package matchtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NakamaModule is an in-memory Nakama module, implementing storage,
+// leaderboard writes, and match creation and listing. Calling other methods
+// panics.
+type NakamaModule struct {
+	runtime.NakamaModule
+
+	// Records are the written leaderboard records, by leaderboard id.
+	Records map[string][]*api.LeaderboardRecord
+	// Matches are the harnesses of the matches using the module, by match id.
+	Matches map[string]*Harness
+
+	objs    map[storageKey]*api.StorageObject
+	modules map[string]runtime.Match
+	version int
+	mu      sync.Mutex
+}
+
+// storageKey is the key of a storage object.
+type storageKey struct {
+	collection, key, userId string
+}
+
+// NewNakamaModule creates an in-memory Nakama module.
+func NewNakamaModule() *NakamaModule {
+	return &NakamaModule{
+		Records: make(map[string][]*api.LeaderboardRecord),
+		Matches: make(map[string]*Harness),
+		objs:    make(map[storageKey]*api.StorageObject),
+		modules: make(map[string]runtime.Match),
+	}
+}
+
+// RegisterMatch registers the match handler created by MatchCreate for the
+// module name.
+func (nk *NakamaModule) RegisterMatch(name string, match runtime.Match) {
+	nk.mu.Lock()
+	defer nk.mu.Unlock()
+	nk.modules[name] = match
+}
+
+// MatchCreate creates a harness for the registered module's match.
+func (nk *NakamaModule) MatchCreate(ctx context.Context, module string, params map[string]interface{}) (string, error) {
+	nk.mu.Lock()
+	match, ok := nk.modules[module]
+	matchId := fmt.Sprintf("match%d.", len(nk.Matches)+1)
+	nk.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("match handler %q not registered", module)
+	}
+	if _, err := New(ctx, matchId, match, params, WithNakamaModule(nk)); err != nil {
+		return "", err
+	}
+	return matchId, nil
+}
+
+// MatchList lists the running matches, ordered by match id. Only queries of
+// required label fields, such as "+label.code:ABC", are supported, and the
+// label and size filters are ignored.
+func (nk *NakamaModule) MatchList(ctx context.Context, limit int, authoritative bool, label string, minSize, maxSize *int, query string) ([]*api.Match, error) {
+	terms := make(map[string]string)
+	for _, term := range strings.Fields(query) {
+		k, v, ok := strings.Cut(strings.TrimPrefix(term, "+label."), ":")
+		if !ok || !strings.HasPrefix(term, "+label.") {
+			return nil, fmt.Errorf("unsupported query term %q", term)
+		}
+		terms[k] = v
+	}
+	nk.mu.Lock()
+	defer nk.mu.Unlock()
+	var matches []*api.Match
+	for matchId, h := range nk.Matches {
+		var fields map[string]interface{}
+		if h.Done || json.Unmarshal([]byte(h.Dispatcher.Label), &fields) != nil {
+			continue
+		}
+		found := true
+		for k, v := range terms {
+			found = found && fields[k] != nil && fmt.Sprint(fields[k]) == v
+		}
+		if found {
+			matches = append(matches, &api.Match{
+				MatchId:       matchId,
+				Authoritative: true,
+				Label:         wrapperspb.String(h.Dispatcher.Label),
+				TickRate:      int32(h.TickRate),
+			})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].MatchId < matches[j].MatchId
+	})
+	if limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (nk *NakamaModule) StorageRead(ctx context.Context, reads []*runtime.StorageRead) ([]*api.StorageObject, error) {
+	nk.mu.Lock()
+	defer nk.mu.Unlock()
+	var objs []*api.StorageObject
+	for _, r := range reads {
+		if obj, ok := nk.objs[storageKey{r.Collection, r.Key, r.UserID}]; ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+// StorageWrite writes the objects, checking the versions of the writes as
+// Nakama does. No objects are written when any version does not match.
+func (nk *NakamaModule) StorageWrite(ctx context.Context, writes []*runtime.StorageWrite) ([]*api.StorageObjectAck, error) {
+	nk.mu.Lock()
+	defer nk.mu.Unlock()
+	for _, w := range writes {
+		obj, ok := nk.objs[storageKey{w.Collection, w.Key, w.UserID}]
+		switch {
+		case w.Version == "":
+		case w.Version == "*" && ok,
+			w.Version != "*" && (!ok || obj.Version != w.Version):
+			return nil, fmt.Errorf("storage write rejected: version check failed for %s/%s/%s", w.Collection, w.Key, w.UserID)
+		}
+	}
+	acks := make([]*api.StorageObjectAck, len(writes))
+	for i, w := range writes {
+		nk.version++
+		obj := &api.StorageObject{
+			Collection:      w.Collection,
+			Key:             w.Key,
+			UserId:          w.UserID,
+			Value:           w.Value,
+			Version:         strconv.Itoa(nk.version),
+			PermissionRead:  int32(w.PermissionRead),
+			PermissionWrite: int32(w.PermissionWrite),
+		}
+		nk.objs[storageKey{w.Collection, w.Key, w.UserID}] = obj
+		acks[i] = &api.StorageObjectAck{
+			Collection: obj.Collection,
+			Key:        obj.Key,
+			Version:    obj.Version,
+			UserId:     obj.UserId,
+		}
+	}
+	return acks, nil
+}
+
+// StorageList lists the user's objects in the collection, ordered by key. The
+// cursor is the offset of the next page.
+func (nk *NakamaModule) StorageList(ctx context.Context, callerID, userID, collection string, limit int, cursor string) ([]*api.StorageObject, string, error) {
+	nk.mu.Lock()
+	defer nk.mu.Unlock()
+	var objs []*api.StorageObject
+	for k, obj := range nk.objs {
+		if k.collection == collection && k.userId == userID {
+			objs = append(objs, obj)
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].Key < objs[j].Key
+	})
+	offset := 0
+	if cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 || offset > len(objs) {
+			return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+	objs, cursor = objs[offset:], ""
+	if limit < len(objs) {
+		objs, cursor = objs[:limit], strconv.Itoa(offset+limit)
+	}
+	return objs, cursor, nil
+}
+
+func (nk *NakamaModule) LeaderboardCreate(ctx context.Context, id string, authoritative bool, sortOrder, operator, resetSchedule string, metadata map[string]interface{}) error {
+	return nil
+}
+
+func (nk *NakamaModule) LeaderboardRecordWrite(ctx context.Context, id, ownerID, username string, score, subscore int64, metadata map[string]interface{}, overrideOperator *int) (*api.LeaderboardRecord, error) {
+	nk.mu.Lock()
+	defer nk.mu.Unlock()
+	record := &api.LeaderboardRecord{
+		LeaderboardId: id,
+		OwnerId:       ownerID,
+		Score:         score,
+		Subscore:      subscore,
+	}
+	nk.Records[id] = append(nk.Records[id], record)
+	return record, nil
+}
+
+// Logger is a runtime logger writing to a logf func, such as testing.T.Logf.
+type Logger struct {
+	logf   func(string, ...interface{})
+	fields map[string]interface{}
+}
+
+// NewLogger creates a runtime logger writing to logf. Messages are discarded
+// when logf is nil.
+func NewLogger(logf func(string, ...interface{})) *Logger {
+	return &Logger{
+		logf: logf,
+	}
+}
+
+func (l *Logger) log(level, format string, v ...interface{}) {
+	if l.logf == nil {
+		return
+	}
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := level + " " + fmt.Sprintf(format, v...)
+	for _, k := range keys {
+		s += fmt.Sprintf(" %s=%v", k, l.fields[k])
+	}
+	l.logf("%s", s)
+}
+
+func (l *Logger) Debug(format string, v ...interface{}) {
+	l.log("DEBUG", format, v...)
+}
+
+func (l *Logger) Info(format string, v ...interface{}) {
+	l.log("INFO", format, v...)
+}
+
+func (l *Logger) Warn(format string, v ...interface{}) {
+	l.log("WARN", format, v...)
+}
+
+func (l *Logger) Error(format string, v ...interface{}) {
+	l.log("ERROR", format, v...)
+}
+
+func (l *Logger) WithField(key string, v interface{}) runtime.Logger {
+	return l.WithFields(map[string]interface{}{key: v})
+}
+
+func (l *Logger) WithFields(fields map[string]interface{}) runtime.Logger {
+	m := make(map[string]interface{}, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		m[k] = v
+	}
+	for k, v := range fields {
+		m[k] = v
+	}
+	return &Logger{
+		logf:   l.logf,
+		fields: m,
+	}
+}
+
+func (l *Logger) Fields() map[string]interface{} {
+	return l.fields
+}
@@ -3,7 +3,9 @@ package nkxoxo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/ascii8/xoxo-go/xoxo"
@@ -14,19 +16,80 @@ import (
 
 const tickRate = 1
 
+// defaultReconnectGrace is the default number of ticks a disconnected
+// player's seat is reserved.
+const defaultReconnectGrace = 30 * tickRate
+
+// maxSpectators is the maximum number of spectators of a match.
+const maxSpectators = 100
+
+// emptyWait is the number of ticks a match waits for its first join, before
+// terminating.
+const emptyWait = 5 * 60 * tickRate
+
+// snapshotInterval is the interval, in state sequence numbers, between full
+// states sent to all presences.
+const snapshotInterval = 30
+
 func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, initializer runtime.Initializer) error {
 	logger.
 		WithField("date", time.Now()).
 		Debug("backend loaded")
-	if err := initializer.RegisterMatch("xoxo", newMatch); err != nil {
+	env, _ := ctx.Value(runtime.RUNTIME_CTX_ENV).(map[string]string)
+	var m match
+	var err error
+	if m.reconnectGrace, err = envTicks(env, "xoxo_reconnect_grace", defaultReconnectGrace); err != nil {
+		return err
+	}
+	if m.timeControl.Game, err = envTicks(env, "xoxo_game_clock", 0); err != nil {
+		return err
+	}
+	if m.timeControl.Turn, err = envTicks(env, "xoxo_turn_clock", 0); err != nil {
+		return err
+	}
+	if m.timeControl.Increment, err = envTicks(env, "xoxo_clock_increment", 0); err != nil {
+		return err
+	}
+	if err := initializer.RegisterMatch("xoxo", m.newMatch); err != nil {
 		return err
 	}
 	if err := initializer.RegisterMatchmakerMatched(matchmakerMatched); err != nil {
 		return err
 	}
+	if err := nk.LeaderboardCreate(ctx, xoxo.LeaderboardRating, true, "desc", "set", "", nil); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc(xoxo.RpcRatings, rpcRatings); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc(xoxo.RpcCreatePrivate, rpcCreatePrivate); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc(xoxo.RpcJoinCode, rpcJoinCode); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc(xoxo.RpcHistory, rpcHistory); err != nil {
+		return err
+	}
+	if err := initializer.RegisterRpc(xoxo.RpcReplay, rpcReplay); err != nil {
+		return err
+	}
 	return nil
 }
 
+// envTicks parses the duration in the runtime env as ticks.
+func envTicks(env map[string]string, key string, def int) (int, error) {
+	str := env[key]
+	if str == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, str, err)
+	}
+	return int(d.Seconds() * tickRate), nil
+}
+
 func matchmakerMatched(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, entries []runtime.MatchmakerEntry) (string, error) {
 	logger.Debug("creating xoxo match")
 	for i, entry := range entries {
@@ -39,21 +102,139 @@ func matchmakerMatched(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 		}
 		l.Debug(fmt.Sprintf("matched user %d", i))
 	}
-	return nk.MatchCreate(ctx, "xoxo", map[string]interface{}{
+	variant, bestOf, err := compatible(entries)
+	if err != nil {
+		logger.
+			WithField("error", err).
+			Error("incompatible matchmaker entries")
+		return "", err
+	}
+	params := map[string]interface{}{
 		"invited": entries,
-	})
+		"variant": variant.String(),
+		"best_of": bestOf,
+	}
+	// a lone player is joined by a bot. The matchmaker only matches a lone
+	// player after waiting for an opponent for its max intervals
+	if len(entries) == 1 {
+		if level, ok := entries[0].GetProperties()[xoxo.PropBot].(string); ok {
+			params["bot"] = level
+		}
+	}
+	return nk.MatchCreate(ctx, "xoxo", params)
 }
 
-type match struct{}
+// compatible checks that the matched entries agree on the variant, region and
+// series length, and that each entry's rating is within the spread requested
+// by the others, returning the agreed variant and series length. The entries'
+// bot levels only need to be valid, as matched players play each other.
+func compatible(entries []runtime.MatchmakerEntry) (xoxo.Variant, int, error) {
+	variant, region, bestOf := xoxo.DefaultVariant, "", 0.0
+	for i, entry := range entries {
+		properties := entry.GetProperties()
+		v := xoxo.DefaultVariant
+		if str, ok := properties[xoxo.PropVariant].(string); ok && str != "" {
+			var err error
+			if v, err = xoxo.ParseVariant(str); err != nil {
+				return xoxo.Variant{}, 0, fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		if str, ok := properties[xoxo.PropBot].(string); ok {
+			if _, err := xoxo.ParseBotLevel(str); err != nil {
+				return xoxo.Variant{}, 0, fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		r, _ := properties[xoxo.PropRegion].(string)
+		b, _ := properties[xoxo.PropBestOf].(float64)
+		switch {
+		case i == 0:
+			variant, region, bestOf = v, r, b
+		case v != variant:
+			return xoxo.Variant{}, 0, fmt.Errorf("entry %d: variant %s != %s", i, v, variant)
+		case r != region:
+			return xoxo.Variant{}, 0, fmt.Errorf("entry %d: region %q != %q", i, r, region)
+		case b != bestOf:
+			return xoxo.Variant{}, 0, fmt.Errorf("entry %d: best of %v != %v", i, b, bestOf)
+		}
+	}
+	for i, a := range entries {
+		spread, ok := a.GetProperties()[xoxo.PropRatingSpread].(float64)
+		if !ok || spread <= 0 {
+			continue
+		}
+		ra, _ := a.GetProperties()[xoxo.PropRating].(float64)
+		for j, b := range entries {
+			rb, ok := b.GetProperties()[xoxo.PropRating].(float64)
+			if i != j && (!ok || math.Abs(ra-rb) > spread) {
+				return xoxo.Variant{}, 0, fmt.Errorf("entry %d: rating outside spread %v of entry %d", j, spread, i)
+			}
+		}
+	}
+	return variant, int(bestOf), nil
+}
 
-func newMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule) (runtime.Match, error) {
-	return match{}, nil
+type match struct {
+	reconnectGrace int
+	timeControl    xoxo.TimeControl
+}
+
+func (m match) newMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule) (runtime.Match, error) {
+	return m, nil
 }
 
 func (m match) MatchInit(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, params map[string]interface{}) (interface{}, int, string) {
 	logger.
 		Debug("MatchInit")
-	return newMatchState(), tickRate, ""
+	variant := xoxo.DefaultVariant
+	if str, ok := params["variant"].(string); ok && str != "" {
+		var err error
+		if variant, err = xoxo.ParseVariant(str); err != nil {
+			logger.
+				WithField("error", err).
+				Error("MatchInit invalid variant")
+			return nil, 0, ""
+		}
+	}
+	bestOf := 0
+	switch v := params["best_of"].(type) {
+	case int:
+		bestOf = v
+	case float64:
+		bestOf = int(v)
+	}
+	if err := xoxo.ValidBestOf(bestOf); err != nil {
+		logger.
+			WithField("error", err).
+			Error("MatchInit invalid best of")
+		return nil, 0, ""
+	}
+	s := newMatchState(variant, m.timeControl)
+	s.state.Series = xoxo.NewSeries(bestOf)
+	s.reconnectGrace = int64(m.reconnectGrace)
+	if str, ok := params["bot"].(string); ok && str != "" {
+		level, err := xoxo.ParseBotLevel(str)
+		if err != nil {
+			logger.
+				WithField("error", err).
+				Error("MatchInit invalid bot level")
+			return nil, 0, ""
+		}
+		s.botLevel = level
+	}
+	var l label
+	if str, ok := params["code"].(string); ok && str != "" {
+		// private matches are casual, unless created as rated
+		rated, _ := params["rated"].(bool)
+		l.Code, s.casual, s.state.Casual = str, !rated, !rated
+	}
+	buf, err := json.Marshal(l)
+	if err != nil {
+		logger.
+			WithField("error", err).
+			Error("MatchInit unable to marshal label")
+		return nil, 0, ""
+	}
+	return s, tickRate, string(buf)
 }
 
 func (m match) MatchJoinAttempt(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presence runtime.Presence, metadata map[string]string) (interface{}, bool, string) {
@@ -62,9 +243,28 @@ func (m match) MatchJoinAttempt(ctx context.Context, logger runtime.Logger, db *
 		WithField("presence", presence).
 		Debug("MatchJoinAttempt")
 	s := state.(*matchState)
-	if err := s.add(presence); err != nil {
+	p, err := newPeer(metadata)
+	if err != nil {
+		return s, false, err.Error()
+	}
+	add := s.add
+	if metadata[xoxo.MetaSpectator] == "true" {
+		add = s.addSpectator
+	}
+	switch {
+	case p.protocol >= 2:
+	case s.state.Variant != xoxo.DefaultVariant:
+		return s, false, fmt.Sprintf("variant %s requires protocol version 2", s.state.Variant)
+	case metadata[xoxo.MetaSpectator] == "true":
+		return s, false, "spectating requires protocol version 2"
+	}
+	if err := add(presence); err != nil {
 		return s, false, err.Error()
 	}
+	if s.peers == nil {
+		s.peers = make(map[string]peer)
+	}
+	s.peers[presence.GetSessionId()] = p
 	return s, true, ""
 }
 
@@ -74,7 +274,12 @@ func (m match) MatchJoin(ctx context.Context, logger runtime.Logger, db *sql.DB,
 		WithField("presences", len(presences)).
 		Debug("MatchJoin")
 	s := state.(*matchState)
-	if len(s.presences) == 2 {
+	for _, presence := range presences {
+		if s.joinTick == 0 && !s.spectating(presence) {
+			s.joinTick = tick
+		}
+	}
+	if s.seated() == 2 {
 		if err := s.broadcastState(logger, dispatcher); err != nil {
 			logger.
 				WithField("tick", tick).
@@ -90,67 +295,341 @@ func (m match) MatchLeave(ctx context.Context, logger runtime.Logger, db *sql.DB
 		WithField("tick", tick).
 		Debug("MatchLeave")
 	s := state.(*matchState)
+	players := 0
+	for _, presence := range presences {
+		if s.spectating(presence) {
+			s.removeSpectator(presence)
+			continue
+		}
+		s.remove(presence, tick)
+		players++
+	}
+	if players == 0 {
+		return s
+	}
+	// without a reconnect grace, leaving abandons the game in progress
+	if s.reconnectGrace == 0 {
+		for i, p := range s.state.Players {
+			if p.Disconnected {
+				s.abandon(i)
+			}
+		}
+	}
+	// there is no game in progress to reconnect to
+	if s.reconnectGrace == 0 || s.seated() != 2 || s.state.Winner != 0 || s.state.Draw {
+		s.finish(tick)
+	}
 	if err := s.broadcastState(logger, dispatcher); err != nil {
 		logger.
 			WithField("tick", tick).
 			WithField("error", err).
-			Debug("MatchJoin unable to broadcast state")
+			Debug("MatchLeave unable to broadcast state")
 	}
-	s.termTick = tick
 	return s
 }
 
 func (m match) MatchLoop(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, messages []runtime.MatchData) interface{} {
 	s := state.(*matchState)
 	l := logger.WithField("tick", tick)
+	defer func() {
+		if (s.state.Winner == 0 && !s.state.Draw) || s.recorded {
+			return
+		}
+		s.recorded = true
+		if err := recordResult(ctx, l, nk, s.state); err != nil {
+			l.
+				WithField("error", err).
+				Error("MatchLoop unable to record result")
+		}
+		if err := recordGame(ctx, l, nk, s); err != nil {
+			l.
+				WithField("error", err).
+				Error("MatchLoop unable to record game")
+		}
+	}()
+	// the other player wins when a disconnected player does not reconnect
+	for i, p := range s.state.Players {
+		if !p.Disconnected || s.termTick != 0 || tick-s.leaveTicks[p.UserId] <= s.reconnectGrace {
+			continue
+		}
+		l.
+			WithField("user_id", p.UserId).
+			Debug("MatchLoop player abandoned match")
+		s.abandon(i)
+		s.finish(tick)
+		if err := s.broadcastState(logger, dispatcher); err != nil {
+			l.
+				WithField("error", err).
+				Debug("MatchLoop unable to broadcast state")
+		}
+	}
 	switch {
-	case len(s.presences) != 2 && s.termTick == 0:
+	case s.joinTick == 0 && tick > emptyWait:
+		l.Debug("MatchLoop terminating unjoined match")
+		return nil
+	case s.seated() != 2 && s.termTick == 0:
+		// the bot takes the other seat once the lone player has joined
+		if s.botLevel == "" || len(s.presences) != 1 {
+			l.
+				Debug("MatchLoop waiting for players")
+			return s
+		}
+		if err := s.addBot(); err != nil {
+			l.
+				WithField("error", err).
+				Error("MatchLoop unable to add bot")
+			return s
+		}
 		l.
-			Debug("MatchLoop waiting for players")
+			WithField("level", s.botLevel).
+			Debug("MatchLoop added bot")
+		if err := s.broadcastState(logger, dispatcher); err != nil {
+			l.
+				WithField("error", err).
+				Debug("MatchLoop unable to broadcast state")
+		}
 		return s
 	case s.termTick != 0 && tick-5 > s.termTick:
 		l.Debug("MatchLoop terminating")
 		return nil
 	}
+	// bot moves on the tick after the other player
+	if s.bot != nil && s.termTick == 0 && s.state.PlayerTurn == s.bot.player(s.state) {
+		move, err := s.bot.move(ctx, s.state)
+		if err == nil {
+			err = s.state.Move(s.bot.userId, move)
+		}
+		switch {
+		case err != nil:
+			l.
+				WithField("error", err).
+				Error("MatchLoop unable to move bot")
+		default:
+			l.
+				WithField("move", move).
+				Debug("MatchLoop bot move")
+			s.record(tick, s.bot.userId, move)
+			if s.state.Winner != 0 || s.state.Draw {
+				s.end(tick)
+			}
+			if err := s.broadcastState(logger, dispatcher); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to broadcast state")
+			}
+		}
+	}
 	for _, m := range messages {
 		data, userId := m.GetData(), m.GetUserId()
 		l := l.WithField("user_id", userId)
 		l.
 			WithField("data", data).
 			Debug("MatchLoop received message")
-		if m.GetOpCode() == xoxo.OpCodeMove {
+		switch m.GetOpCode() {
+		case xoxo.OpCodeMove:
 			var move xoxo.Move
-			if err := move.Unmarshal(data); err != nil {
+			if err := s.peer(m).codec.Unmarshal(data, &move); err != nil {
 				l.
 					WithField("data", data).
 					WithField("error", err).
 					Debug("MessageLoop unable to decode message")
+				s.sendError(l, dispatcher, m, fmt.Errorf("%w: %v", xoxo.ErrInvalidMessage, err), nil)
 				continue
 			}
 			l = l.WithField("move", move)
 			l.
 				WithField("state", s.state.String()).
 				Debug("MatchLoop move")
+			if err := s.checkSeq(m, move); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MessageLoop stale move")
+				s.sendError(l, dispatcher, m, err, &move)
+				continue
+			}
 			if err := s.state.Move(userId, move); err != nil {
 				l.
 					WithField("error", err).
 					Debug("MessageLoop unable to move")
+				s.sendError(l, dispatcher, m, err, &move)
+				continue
 			}
+			s.record(tick, userId, move)
 			// ended
 			if s.state.Winner != 0 || s.state.Draw {
-				s.state.RematchCountdown = 10 * tickRate
+				s.end(tick)
+			}
+			if err := s.broadcastState(logger, dispatcher); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to broadcast state")
+			}
+		case xoxo.OpCodeTakebackRequest:
+			if err := s.requestTakeback(userId); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to request takeback")
+				s.sendError(l, dispatcher, m, err, nil)
+				continue
+			}
+			l.
+				WithField("state", s.state.String()).
+				Debug("MatchLoop takeback requested")
+			if err := s.broadcastState(logger, dispatcher); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to broadcast state")
+			}
+		case xoxo.OpCodeTakebackResponse:
+			var res xoxo.Response
+			if err := s.peer(m).codec.Unmarshal(data, &res); err != nil {
+				l.
+					WithField("data", data).
+					WithField("error", err).
+					Debug("MessageLoop unable to decode message")
+				s.sendError(l, dispatcher, m, fmt.Errorf("%w: %v", xoxo.ErrInvalidMessage, err), nil)
+				continue
+			}
+			if err := s.respondTakeback(userId, res.Accept); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to respond to takeback")
+				s.sendError(l, dispatcher, m, err, nil)
+				continue
+			}
+			l.
+				WithField("accept", res.Accept).
+				WithField("state", s.state.String()).
+				Debug("MatchLoop takeback response")
+			if err := s.broadcastState(logger, dispatcher); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to broadcast state")
+			}
+		case xoxo.OpCodeResign:
+			if err := s.state.Resign(s.seat(userId)); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to resign")
+				s.sendError(l, dispatcher, m, err, nil)
+				continue
+			}
+			l.
+				WithField("state", s.state.String()).
+				Debug("MatchLoop player resigned")
+			s.end(tick)
+			if err := s.broadcastState(logger, dispatcher); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to broadcast state")
+			}
+		case xoxo.OpCodeDrawOffer:
+			if err := s.offerDraw(userId); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to offer draw")
+				s.sendError(l, dispatcher, m, err, nil)
+				continue
 			}
+			l.
+				WithField("state", s.state.String()).
+				Debug("MatchLoop draw offered")
 			if err := s.broadcastState(logger, dispatcher); err != nil {
 				l.
 					WithField("error", err).
 					Debug("MatchLoop unable to broadcast state")
 			}
+		case xoxo.OpCodeRematch:
+			var res xoxo.Response
+			if err := s.peer(m).codec.Unmarshal(data, &res); err != nil {
+				l.
+					WithField("data", data).
+					WithField("error", err).
+					Debug("MessageLoop unable to decode message")
+				s.sendError(l, dispatcher, m, fmt.Errorf("%w: %v", xoxo.ErrInvalidMessage, err), nil)
+				continue
+			}
+			if err := s.voteRematch(userId, res.Accept, tick); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to vote rematch")
+				s.sendError(l, dispatcher, m, err, nil)
+				continue
+			}
+			l.
+				WithField("accept", res.Accept).
+				WithField("state", s.state.String()).
+				Debug("MatchLoop rematch vote")
+			if err := s.broadcastState(logger, dispatcher); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to broadcast state")
+			}
+		case xoxo.OpCodeDrawResponse:
+			var res xoxo.Response
+			if err := s.peer(m).codec.Unmarshal(data, &res); err != nil {
+				l.
+					WithField("data", data).
+					WithField("error", err).
+					Debug("MessageLoop unable to decode message")
+				s.sendError(l, dispatcher, m, fmt.Errorf("%w: %v", xoxo.ErrInvalidMessage, err), nil)
+				continue
+			}
+			if err := s.respondDraw(userId, res.Accept); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to respond to draw offer")
+				s.sendError(l, dispatcher, m, err, nil)
+				continue
+			}
+			l.
+				WithField("accept", res.Accept).
+				WithField("state", s.state.String()).
+				Debug("MatchLoop draw response")
+			if s.state.Draw {
+				s.end(tick)
+			}
+			if err := s.broadcastState(logger, dispatcher); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to broadcast state")
+			}
+		case xoxo.OpCodeResync:
+			if err := s.resync(l, dispatcher, m); err != nil {
+				l.
+					WithField("error", err).
+					Debug("MatchLoop unable to resync")
+				s.sendError(l, dispatcher, m, err, nil)
+				continue
+			}
+			l.
+				WithField("seq", s.seq).
+				Debug("MatchLoop resync")
+		default:
+			l.
+				WithField("op_code", m.GetOpCode()).
+				Debug("MatchLoop unknown op code")
+			s.sendError(l, dispatcher, m, fmt.Errorf("%w: unknown op code %d", xoxo.ErrInvalidMessage, m.GetOpCode()), nil)
+		}
+	}
+	// forfeit when the player to move runs out of time
+	if s.state.Tick() {
+		l.
+			WithField("player", s.state.Winner.Int()).
+			Debug("MatchLoop player forfeits on time")
+		s.end(tick)
+		if err := s.broadcastState(logger, dispatcher); err != nil {
+			l.
+				WithField("error", err).
+				Debug("MatchLoop unable to broadcast state")
 		}
+		return s
 	}
 	if s.state.RematchCountdown > 0 {
 		s.state.RematchCountdown--
+		// the match ends when the players do not agree to a rematch
 		if s.state.RematchCountdown == 0 {
-			s.rematch()
+			s.finish(tick)
 		}
 		if err := s.broadcastState(logger, dispatcher); err != nil {
 			l.
@@ -173,45 +652,295 @@ func (m match) MatchSignal(ctx context.Context, logger runtime.Logger, db *sql.D
 }
 
 type matchState struct {
-	state     *xoxo.State
-	presences []runtime.Presence
-	termTick  int64
+	variant        xoxo.Variant
+	timeControl    xoxo.TimeControl
+	state          *xoxo.State
+	presences      []runtime.Presence
+	spectators     []runtime.Presence
+	joinTick       int64
+	termTick       int64
+	reconnectGrace int64
+	leaveTicks     map[string]int64
+	recorded       bool
+	casual         bool
+	first          int
+	moves          []xoxo.RecordedMove
+	seq            int64
+	seqHash        string
+	seqMoved       int64
+	sent           map[string]*xoxo.MatchState
+	peers          map[string]peer
+	history        xoxo.MatchRecord
+	botLevel       xoxo.BotLevel
+	bot            *bot
 }
 
-func newMatchState() *matchState {
-	return &matchState{
-		state: xoxo.NewState(),
+func newMatchState(variant xoxo.Variant, timeControl xoxo.TimeControl) *matchState {
+	s := &matchState{
+		variant:     variant,
+		timeControl: timeControl,
 	}
+	s.state, _ = xoxo.NewVariantState(variant)
+	s.state.SetTimeControl(timeControl)
+	return s
 }
 
+// end ends the game, starting the rematch countdown unless the series is
+// over.
+func (s *matchState) end(tick int64) {
+	s.state.Series.Add(s.state.Winner)
+	if s.state.Series.Over() {
+		s.finish(tick)
+		return
+	}
+	s.state.RematchCountdown = 10 * tickRate
+	s.state.Rematch = make([]bool, 2)
+	// bots always agree to a rematch
+	if s.bot != nil {
+		s.state.Rematch[s.bot.player(s.state)-1] = true
+	}
+}
+
+// abandon ends the game in progress, won by the other player, when the
+// player i has abandoned the match.
+func (s *matchState) abandon(i int) {
+	if s.state.Winner != 0 || s.state.Draw || s.seated() != 2 {
+		return
+	}
+	s.state.Winner, s.state.Reason, s.state.PlayerTurn = xoxo.Winner(2-i), xoxo.ReasonAbandoned, -1
+	s.state.Series.Add(s.state.Winner)
+}
+
+// finish finishes the match, terminating it.
+func (s *matchState) finish(tick int64) {
+	s.state.Finished, s.state.RematchCountdown, s.state.Rematch = true, 0, nil
+	if s.termTick == 0 {
+		s.termTick = tick
+	}
+}
+
+// voteRematch records the user's rematch vote, starting the next game when
+// both players agree to a rematch, and finishing the match when either
+// declines.
+func (s *matchState) voteRematch(userId string, accept bool, tick int64) error {
+	p := s.seat(userId)
+	switch {
+	case p == 0:
+		return fmt.Errorf("%w: user %s is not seated", xoxo.ErrNotSeated, userId)
+	case s.state.RematchCountdown == 0 || len(s.state.Rematch) != 2:
+		return fmt.Errorf("%w: no rematch pending", xoxo.ErrNotAllowed)
+	case !accept:
+		s.finish(tick)
+		return nil
+	}
+	s.state.Rematch[p-1] = true
+	if s.state.Rematch[0] && s.state.Rematch[1] {
+		s.rematch()
+	}
+	return nil
+}
+
+// rematch starts the next game, with the other player moving first.
 func (s *matchState) rematch() {
-	s.state = xoxo.NewState()
-	if err := s.state.Add(
-		s.presences[0].GetNodeId(),
-		s.presences[0].GetSessionId(),
-		s.presences[0].GetUserId(),
-		s.presences[0].GetUsername(),
-	); err != nil {
-		panic(err)
+	players, series := s.state.Players, s.state.Series
+	s.state, _ = xoxo.NewVariantState(s.variant)
+	s.state.SetTimeControl(s.timeControl)
+	s.state.Players = players
+	s.state.Casual = s.casual
+	s.state.Series = series
+	s.state.PlayerTurn = 1 + series.Games%2
+	s.first = s.state.PlayerTurn
+	s.recorded, s.moves = false, nil
+}
+
+// nextSeq increments the state sequence number, noting the sequence number
+// when the position changed.
+func (s *matchState) nextSeq() {
+	s.seq++
+	if hash := s.state.Hash(); hash != s.seqHash {
+		s.seqHash, s.seqMoved = hash, s.seq
 	}
-	if err := s.state.Add(
-		s.presences[1].GetNodeId(),
-		s.presences[1].GetSessionId(),
-		s.presences[1].GetUserId(),
-		s.presences[1].GetUsername(),
-	); err != nil {
-		panic(err)
+}
+
+// checkSeq checks that the move was made against the current position. Moves
+// from version 1 clients, which have no sequence number, are not checked.
+func (s *matchState) checkSeq(presence runtime.Presence, move xoxo.Move) error {
+	if s.peer(presence).protocol < 2 {
+		return nil
+	}
+	if move.Seq < s.seqMoved || s.seq < move.Seq {
+		return fmt.Errorf("%w: move made against state %d, position changed at state %d", xoxo.ErrStaleMove, move.Seq, s.seqMoved)
 	}
+	return nil
 }
 
-func (s *matchState) add(presence runtime.Presence) error {
-	if len(s.presences) == 2 {
-		return fmt.Errorf("cannot have more than 2 players in a game")
+// record records the accepted move.
+func (s *matchState) record(tick int64, userId string, move xoxo.Move) {
+	s.moves = append(s.moves, xoxo.RecordedMove{
+		Tick:   tick,
+		UserId: userId,
+		Move:   xoxo.Move{Row: move.Row, Col: move.Col},
+		Hash:   s.state.Hash(),
+	})
+}
+
+// seated returns the number of seated players, including the bot.
+func (s *matchState) seated() int {
+	return len(s.state.Players)
+}
+
+// addBot adds a bot to the empty seat.
+func (s *matchState) addBot() error {
+	b := newBot(s.botLevel, s.variant)
+	if err := s.state.AddBot(b.userId, b.username); err != nil {
+		return err
+	}
+	// games against a bot are casual
+	s.bot, s.casual, s.state.Casual = b, true, true
+	return nil
+}
+
+// seat returns the player number for the user.
+func (s *matchState) seat(userId string) int {
+	for i, p := range s.state.Players {
+		if p.UserId == userId {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// requestTakeback requests a takeback for the user. A bot opponent accepts
+// the takeback immediately.
+func (s *matchState) requestTakeback(userId string) error {
+	p := s.seat(userId)
+	switch {
+	case !s.state.Casual:
+		return fmt.Errorf("%w: takebacks are not permitted", xoxo.ErrNotAllowed)
+	case p == 0:
+		return fmt.Errorf("%w: user %s is not seated", xoxo.ErrNotSeated, userId)
+	case s.state.PlayerTurn != 1 && s.state.PlayerTurn != 2:
+		return fmt.Errorf("%w: game is not in progress", xoxo.ErrGameOver)
+	case s.state.Takeback != 0:
+		return fmt.Errorf("%w: player %d already requested a takeback", xoxo.ErrNotAllowed, s.state.Takeback)
+	}
+	moved := false
+	for _, m := range s.state.Moves {
+		moved = moved || s.state.Cells[m.Row-1][m.Col-1] == p
+	}
+	if !moved {
+		return fmt.Errorf("%w: player %d has no move to take back", xoxo.ErrNotAllowed, p)
+	}
+	s.state.Takeback = p
+	if s.bot != nil {
+		return s.takeback()
+	}
+	return nil
+}
+
+// respondTakeback responds to the other player's pending takeback request.
+func (s *matchState) respondTakeback(userId string, accept bool) error {
+	p := s.seat(userId)
+	switch {
+	case s.state.Takeback == 0:
+		return fmt.Errorf("%w: no takeback requested", xoxo.ErrNotAllowed)
+	case p == 0 || p == s.state.Takeback:
+		return fmt.Errorf("%w: user %s cannot respond to the takeback", xoxo.ErrNotAllowed, userId)
+	case !accept:
+		s.state.Takeback = 0
+		return nil
+	}
+	return s.takeback()
+}
+
+// offerDraw offers a draw to the other player. A bot opponent declines the
+// offer.
+func (s *matchState) offerDraw(userId string) error {
+	p := s.seat(userId)
+	switch {
+	case p == 0:
+		return fmt.Errorf("%w: user %s is not seated", xoxo.ErrNotSeated, userId)
+	case s.state.PlayerTurn != 1 && s.state.PlayerTurn != 2:
+		return fmt.Errorf("%w: game is not in progress", xoxo.ErrGameOver)
+	case s.state.DrawOffer != 0:
+		return fmt.Errorf("%w: player %d already offered a draw", xoxo.ErrNotAllowed, s.state.DrawOffer)
+	case s.bot != nil:
+		return nil
+	}
+	s.state.DrawOffer = p
+	return nil
+}
+
+// respondDraw responds to the other player's pending draw offer.
+func (s *matchState) respondDraw(userId string, accept bool) error {
+	p := s.seat(userId)
+	switch {
+	case s.state.DrawOffer == 0:
+		return fmt.Errorf("%w: no draw offered", xoxo.ErrNotAllowed)
+	case p == 0 || p == s.state.DrawOffer:
+		return fmt.Errorf("%w: user %s cannot respond to the draw offer", xoxo.ErrNotAllowed, userId)
+	case !accept:
+		s.state.DrawOffer = 0
+		return nil
+	}
+	s.state.Draw, s.state.Reason, s.state.PlayerTurn = true, xoxo.ReasonAgreed, -1
+	s.state.DrawOffer, s.state.Takeback = 0, 0
+	return nil
+}
+
+// takeback undoes moves until it is the requesting player's turn.
+func (s *matchState) takeback() error {
+	p := s.state.Takeback
+	for {
+		if err := s.state.Undo(); err != nil {
+			return err
+		}
+		if len(s.moves) != 0 {
+			s.moves = s.moves[:len(s.moves)-1]
+		}
+		if s.state.PlayerTurn == p {
+			return nil
+		}
 	}
-	for _, p := range s.presences {
-		if p.GetUserId() == presence.GetUserId() {
+}
+
+// player returns the player number for the presence.
+func (s *matchState) player(presence runtime.Presence) int {
+	return s.seat(presence.GetUserId())
+}
+
+func (s *matchState) add(presence runtime.Presence) error {
+	stale := -1
+	for i, p := range s.presences {
+		switch {
+		case p.GetUserId() != presence.GetUserId():
+		case p.GetSessionId() == presence.GetSessionId():
 			return fmt.Errorf("presence %s already added", p.GetUserId())
+		default:
+			// rejoining before the leave was processed
+			stale = i
+		}
+	}
+	// reclaim the player's seat
+	for i, p := range s.state.Players {
+		if p.UserId != presence.GetUserId() {
+			continue
 		}
+		if (!p.Disconnected && stale == -1) || s.termTick != 0 {
+			return fmt.Errorf("player %s cannot rejoin", p.UserId)
+		}
+		if stale != -1 {
+			s.presences = append(s.presences[:stale], s.presences[stale+1:]...)
+		}
+		s.state.Players[i].Node = presence.GetNodeId()
+		s.state.Players[i].SessionId = presence.GetSessionId()
+		s.state.Players[i].Disconnected = false
+		delete(s.leaveTicks, p.UserId)
+		s.presences = append(s.presences, presence)
+		return nil
+	}
+	if len(s.presences) == 2 {
+		return fmt.Errorf("cannot have more than 2 players in a game")
 	}
 	if err := s.state.Add(
 		presence.GetNodeId(),
@@ -225,33 +954,297 @@ func (s *matchState) add(presence runtime.Presence) error {
 	return nil
 }
 
+// spectating returns true when the presence is a spectator.
+func (s *matchState) spectating(presence runtime.Presence) bool {
+	for _, p := range s.spectators {
+		if p.GetSessionId() == presence.GetSessionId() {
+			return true
+		}
+	}
+	return false
+}
+
+// addSpectator adds the presence as a spectator.
+func (s *matchState) addSpectator(presence runtime.Presence) error {
+	switch {
+	case s.player(presence) != 0:
+		return fmt.Errorf("player %s cannot spectate", presence.GetUserId())
+	case s.spectating(presence):
+		return fmt.Errorf("spectator %s already added", presence.GetUserId())
+	case len(s.spectators) >= maxSpectators:
+		return fmt.Errorf("cannot have more than %d spectators", maxSpectators)
+	}
+	s.spectators = append(s.spectators, presence)
+	return nil
+}
+
+// removeSpectator removes the spectator presence.
+func (s *matchState) removeSpectator(presence runtime.Presence) {
+	for i, p := range s.spectators {
+		if p.GetSessionId() == presence.GetSessionId() {
+			s.spectators = append(s.spectators[:i], s.spectators[i+1:]...)
+			delete(s.peers, p.GetSessionId())
+			return
+		}
+	}
+}
+
+// remove removes the presence, reserving its seat.
+func (s *matchState) remove(presence runtime.Presence, tick int64) {
+	for i, p := range s.presences {
+		if p.GetSessionId() == presence.GetSessionId() {
+			s.presences = append(s.presences[:i], s.presences[i+1:]...)
+			delete(s.peers, p.GetSessionId())
+			break
+		}
+	}
+	for i, p := range s.state.Players {
+		if p.UserId == presence.GetUserId() && p.SessionId == presence.GetSessionId() {
+			s.state.Players[i].Disconnected = true
+			if s.leaveTicks == nil {
+				s.leaveTicks = make(map[string]int64)
+			}
+			s.leaveTicks[p.UserId] = tick
+		}
+	}
+}
+
 func (s *matchState) broadcastState(logger runtime.Logger, dispatcher runtime.MatchDispatcher) error {
-	if len(s.presences) != 2 {
-		return fmt.Errorf("invalid presences length %d", len(s.presences))
+	if s.seated() != 2 {
+		return fmt.Errorf("invalid seated players %d", s.seated())
 	}
+	s.nextSeq()
 	logger.
 		WithField("state", s.state.String()).
+		WithField("seq", s.seq).
 		Debug("broadcast state")
-	active, other := &s.state.Players[0], &s.state.Players[1]
-	if s.state.PlayerTurn == 2 {
-		active, other = other, active
+	// full states are sent periodically, and to presences that have not been
+	// sent a previous state
+	snapshot := s.seq%snapshotInterval == 0
+	sent := make(map[string]*xoxo.MatchState, len(s.presences)+len(s.spectators))
+	for _, presence := range s.presences {
+		state := s.playerView(presence)
+		if err := s.sendState(logger, dispatcher, []runtime.Presence{presence}, s.sent[presence.GetSessionId()], state, snapshot); err != nil {
+			return err
+		}
+		sent[presence.GetSessionId()] = state
 	}
-	for i := 0; i < 2; i++ {
-		data, err := (&xoxo.MatchState{
-			ActivePlayer: active,
-			OtherPlayer:  other,
-			State:        s.state,
-			YourTurn:     s.state.PlayerTurn == (i + 1),
-		}).Marshal()
-		if err != nil {
-			return fmt.Errorf("unable to marshal message for %s: %w", s.presences[i].GetSessionId(), err)
+	if len(s.spectators) != 0 {
+		// spectators sent the same previous view, with the same codec and
+		// protocol version, share the message
+		type group struct {
+			prev *xoxo.MatchState
+			peer peer
 		}
-		logger.
-			WithField("data", data).
-			Debug("sending")
-		if err := dispatcher.BroadcastMessage(xoxo.OpCodeState, data, s.presences[i:i+1], nil, true); err != nil {
-			return fmt.Errorf("unable to broadcast message for %s: %w", s.presences[i].GetSessionId(), err)
+		view, groups := s.spectatorView(), make(map[group][]runtime.Presence)
+		for _, presence := range s.spectators {
+			g := group{s.sent[presence.GetSessionId()], s.peer(presence)}
+			groups[g] = append(groups[g], presence)
+			sent[presence.GetSessionId()] = view
 		}
+		for g, presences := range groups {
+			if err := s.sendState(logger, dispatcher, presences, g.prev, view, snapshot); err != nil {
+				return err
+			}
+		}
+	}
+	s.sent = sent
+	return nil
+}
+
+// resync sends the full state to the presence.
+func (s *matchState) resync(logger runtime.Logger, dispatcher runtime.MatchDispatcher, presence runtime.Presence) error {
+	if s.seated() != 2 || s.seq == 0 {
+		return fmt.Errorf("%w: no state to resync", xoxo.ErrNotAllowed)
+	}
+	state := s.playerView(presence)
+	if s.spectating(presence) {
+		state = s.spectatorView()
+	}
+	if err := s.sendState(logger, dispatcher, []runtime.Presence{presence}, nil, state, true); err != nil {
+		return err
+	}
+	if s.sent == nil {
+		s.sent = make(map[string]*xoxo.MatchState)
+	}
+	s.sent[presence.GetSessionId()] = state
+	return nil
+}
+
+// sendState sends the state to the presences, as a delta from the previous
+// state when possible. The presences must share the same peer.
+func (s *matchState) sendState(logger runtime.Logger, dispatcher runtime.MatchDispatcher, presences []runtime.Presence, prev, state *xoxo.MatchState, snapshot bool) error {
+	p := s.peer(presences[0])
+	var opCode int64 = xoxo.OpCodeState
+	var msg interface{} = state
+	switch d, ok := xoxo.Diff(prev, state); {
+	case p.protocol < 2:
+		msg = newV1MatchState(state)
+	case ok && !snapshot:
+		opCode, msg = xoxo.OpCodeDelta, d
+	}
+	data, err := p.codec.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("unable to marshal message for %s: %w", presences[0].GetSessionId(), err)
+	}
+	logger.
+		WithField("op_code", opCode).
+		WithField("data", data).
+		Debug("sending")
+	if err := dispatcher.BroadcastMessage(opCode, data, presences, nil, true); err != nil {
+		return fmt.Errorf("unable to broadcast message for %s: %w", presences[0].GetSessionId(), err)
 	}
 	return nil
 }
+
+// peer is the codec and protocol version negotiated with a presence.
+type peer struct {
+	codec    xoxo.Codec
+	protocol int
+}
+
+// newPeer negotiates the codec and protocol version from the match join
+// metadata. Version 1 clients send no protocol version, and only support the
+// JSON codec.
+func newPeer(metadata map[string]string) (peer, error) {
+	version, err := xoxo.ParseProtocol(metadata[xoxo.MetaProtocol])
+	if err != nil {
+		return peer{}, err
+	}
+	protocol, err := xoxo.NegotiateProtocol(version)
+	if err != nil {
+		return peer{}, err
+	}
+	codec, err := xoxo.ParseCodec(metadata[xoxo.MetaCodec])
+	switch {
+	case err != nil:
+		return peer{}, err
+	case codec != xoxo.JSONCodec && protocol < 2:
+		return peer{}, fmt.Errorf("codec %s requires protocol version 2", codec.Name())
+	}
+	return peer{codec: codec, protocol: protocol}, nil
+}
+
+// peer returns the peer for the presence.
+func (s *matchState) peer(presence runtime.Presence) peer {
+	if p, ok := s.peers[presence.GetSessionId()]; ok {
+		return p
+	}
+	return peer{codec: xoxo.JSONCodec, protocol: xoxo.ProtocolVersion}
+}
+
+// playerView returns the view of the match for the player's presence.
+func (s *matchState) playerView(presence runtime.Presence) *xoxo.MatchState {
+	state := s.state.Copy()
+	active, other := &state.Players[0], &state.Players[1]
+	if state.PlayerTurn == 2 {
+		active, other = other, active
+	}
+	return &xoxo.MatchState{
+		ActivePlayer: active,
+		OtherPlayer:  other,
+		State:        state,
+		YourTurn:     state.PlayerTurn == s.player(presence),
+		Seq:          s.seq,
+	}
+}
+
+// sendError sends the error for the rejected message to its sender.
+func (s *matchState) sendError(logger runtime.Logger, dispatcher runtime.MatchDispatcher, m runtime.MatchData, cause error, move *xoxo.Move) {
+	p := s.peer(m)
+	// version 1 clients do not understand errors
+	if p.protocol < 2 {
+		return
+	}
+	data, err := p.codec.Marshal(xoxo.NewMatchError(m.GetOpCode(), cause, move))
+	if err != nil {
+		logger.
+			WithField("error", err).
+			Error("unable to marshal error")
+		return
+	}
+	if err := dispatcher.BroadcastMessage(xoxo.OpCodeError, data, []runtime.Presence{m}, nil, true); err != nil {
+		logger.
+			WithField("error", err).
+			Debug("unable to send error")
+	}
+}
+
+// spectatorView returns the match state sent to spectators, without the
+// players' session details.
+func (s *matchState) spectatorView() *xoxo.MatchState {
+	state := s.state.Copy()
+	state.Players = publicPlayers(s.state.Players)
+	active, other := &state.Players[0], &state.Players[1]
+	if state.PlayerTurn == 2 {
+		active, other = other, active
+	}
+	return &xoxo.MatchState{
+		ActivePlayer: active,
+		OtherPlayer:  other,
+		State:        state,
+		Spectator:    true,
+		Seq:          s.seq,
+	}
+}
+
+// v1MatchState is the match state sent to version 1 clients, with only the
+// fields they decode.
+type v1MatchState struct {
+	ActivePlayer *v1Player `json:"active_player,omitempty"`
+	OtherPlayer  *v1Player `json:"other_player,omitempty"`
+	State        *v1State  `json:"state,omitempty"`
+	YourTurn     bool      `json:"your_turn"`
+}
+
+// v1State is the game state sent to version 1 clients.
+type v1State struct {
+	Cells            [][]int     `json:"cells,omitempty"`
+	PlayerTurn       int         `json:"player_turn"`
+	Players          []v1Player  `json:"players"`
+	Winner           xoxo.Winner `json:"winner,omitempty"`
+	Draw             bool        `json:"draw,omitempty"`
+	RematchCountdown int         `json:"rematch_countdown,omitempty"`
+}
+
+// v1Player is a player sent to version 1 clients.
+type v1Player struct {
+	Node      string `json:"node,omitempty"`
+	SessionId string `json:"session_id,omitempty"`
+	UserId    string `json:"user_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// newV1MatchState creates the version 1 match state for the state.
+func newV1MatchState(m *xoxo.MatchState) *v1MatchState {
+	player := func(p *xoxo.Player) *v1Player {
+		if p == nil {
+			return nil
+		}
+		return &v1Player{
+			Node:      p.Node,
+			SessionId: p.SessionId,
+			UserId:    p.UserId,
+			Username:  p.Username,
+		}
+	}
+	v := &v1MatchState{
+		ActivePlayer: player(m.ActivePlayer),
+		OtherPlayer:  player(m.OtherPlayer),
+		YourTurn:     m.YourTurn,
+	}
+	if s := m.State; s != nil {
+		v.State = &v1State{
+			Cells:            s.Cells,
+			PlayerTurn:       s.PlayerTurn,
+			Players:          make([]v1Player, len(s.Players)),
+			Winner:           s.Winner,
+			Draw:             s.Draw,
+			RematchCountdown: s.RematchCountdown,
+		}
+		for i := range s.Players {
+			v.State.Players[i] = *player(&s.Players[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,534 @@
+package nkxoxo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ascii8/xoxo-go/nkxoxo/matchtest"
+	"github.com/ascii8/xoxo-go/xoxo"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+func TestMatchPlay(t *testing.T) {
+	h, p1, p2 := newMatch(t, match{}, nil)
+	for _, p := range []*matchtest.Presence{p1, p2} {
+		if state := lastState(t, h, p); state == nil || len(state.State.Players) != 2 {
+			t.Fatalf("expected state for %s, got: %+v", p.UserId, state)
+		}
+	}
+	playWin(t, h, p1, p2)
+	state := lastState(t, h, p2)
+	if state.State.Winner != 1 || state.State.RematchCountdown == 0 || state.YourTurn {
+		t.Errorf("expected player 1 win with rematch countdown, got: %s", state.State)
+	}
+	if n := len(h.NK.Records[xoxo.LeaderboardRating]); n != 2 {
+		t.Errorf("expected 2 leaderboard records, got: %d", n)
+	}
+	objs, _, err := h.NK.StorageList(h.Ctx, p1.UserId, p1.UserId, historyCollection, 10, "")
+	if err != nil || len(objs) != 1 {
+		t.Fatalf("expected 1 match record, got: %d %v", len(objs), err)
+	}
+	var record xoxo.MatchRecord
+	if err := json.Unmarshal([]byte(objs[0].GetValue()), &record); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if record.MatchId != "match" || len(record.Games) != 1 || len(record.Games[0].Moves) != 5 {
+		t.Fatalf("expected match record with 1 game of 5 moves, got: %+v", record)
+	}
+	if _, err := record.Games[0].Replay(); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	// no rematch
+	h.Run(10 * tickRate)
+	if state := lastState(t, h, p1); !state.State.Finished {
+		t.Errorf("expected finished match, got: %s", state.State)
+	}
+	if h.Run(10) {
+		t.Errorf("expected match to terminate")
+	}
+}
+
+func TestMatchRematch(t *testing.T) {
+	h, p1, p2 := newMatch(t, match{}, map[string]interface{}{
+		"best_of": 3,
+	})
+	playWin(t, h, p1, p2)
+	for _, p := range []*matchtest.Presence{p1, p2} {
+		send(t, h, p, xoxo.OpCodeRematch, xoxo.Response{Accept: true})
+	}
+	h.Step()
+	state := lastState(t, h, p2)
+	switch {
+	case state.State.Winner != 0 || state.State.Finished || len(state.State.Moves) != 0:
+		t.Fatalf("expected new game, got: %s", state.State)
+	case state.State.PlayerTurn != 2 || !state.YourTurn:
+		t.Errorf("expected player 2 to move first, got: %s", state.State)
+	case state.State.Series.Games != 1 || state.State.Series.Wins[0] != 1:
+		t.Errorf("expected series score 1-0, got: %s", state.State.Series)
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	h, p1, p2 := newMatch(t, match{}, nil)
+	tests := []struct {
+		p      *matchtest.Presence
+		opCode int64
+		data   []byte
+		code   xoxo.ErrorCode
+	}{
+		{p2, xoxo.OpCodeMove, moveData(t, h, p2, 0, 0), xoxo.ErrorNotYourTurn},
+		{p1, xoxo.OpCodeMove, []byte(`{`), xoxo.ErrorInvalidMessage},
+		{p1, xoxo.OpCodeMove, moveData(t, h, p1, 3, 3), xoxo.ErrorInvalidMove},
+		{p1, xoxo.OpCodeResign + 100, nil, xoxo.ErrorInvalidMessage},
+		{p1, xoxo.OpCodeTakebackRequest, nil, xoxo.ErrorNotAllowed},
+		{p2, xoxo.OpCodeDrawResponse, mustMarshal(t, xoxo.Response{Accept: true}), xoxo.ErrorNotAllowed},
+	}
+	for i, test := range tests {
+		h.Send(test.p, test.opCode, test.data)
+		h.Step()
+		msg, ok := h.Last(test.p, xoxo.OpCodeError)
+		if !ok || msg.Tick != h.Tick-1 {
+			t.Fatalf("test %d expected error message", i)
+		}
+		merr := new(xoxo.MatchError)
+		if err := merr.Unmarshal(msg.Data); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if merr.Code != test.code || merr.OpCode != test.opCode {
+			t.Errorf("test %d expected %s for op code %d, got: %+v", i, test.code, test.opCode, merr)
+		}
+	}
+	// a move made against an old position is stale
+	stale := moveData(t, h, p1, 0, 0)
+	move(t, h, p1, 1, 1)
+	move(t, h, p2, 2, 2)
+	h.Send(p1, xoxo.OpCodeMove, stale)
+	h.Step()
+	msg, _ := h.Last(p1, xoxo.OpCodeError)
+	merr := new(xoxo.MatchError)
+	if err := merr.Unmarshal(msg.Data); err != nil || !errors.Is(merr, xoxo.ErrStaleMove) {
+		t.Errorf("expected stale move error, got: %v %v", merr, err)
+	}
+	if state := lastState(t, h, p1); len(state.State.Moves) != 2 {
+		t.Errorf("expected 2 moves, got: %s", state.State)
+	}
+}
+
+func TestMatchProtocol(t *testing.T) {
+	h, err := matchtest.New(context.Background(), "match", match{}, nil, matchtest.WithLogf(t.Logf))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	p1, p2, p3 := matchtest.NewPresence("1"), matchtest.NewPresence("2"), matchtest.NewPresence("3")
+	for i, md := range []map[string]string{
+		{xoxo.MetaProtocol: "0"},
+		{xoxo.MetaProtocol: "2", xoxo.MetaCodec: "xml"},
+		{xoxo.MetaCodec: xoxo.BinaryCodec.Name()},
+		{xoxo.MetaSpectator: "true"},
+	} {
+		if err := h.Join(p3, md); err == nil {
+			t.Errorf("test %d expected join to be rejected", i)
+		}
+	}
+	// version 1 clients send no metadata
+	if err := h.Join(p1, nil); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := h.Join(p2, map[string]string{xoxo.MetaProtocol: strconv.Itoa(xoxo.ProtocolVersion + 1), xoxo.MetaCodec: xoxo.BinaryCodec.Name()}); err != nil {
+		t.Fatalf("expected newer client to be downgraded, got: %v", err)
+	}
+	h.Step()
+	// moves from version 1 clients have no sequence number, and errors are
+	// not sent to them
+	h.Send(p1, xoxo.OpCodeMove, mustMarshal(t, xoxo.NewMove(0, 0)))
+	h.Step()
+	h.Send(p1, xoxo.OpCodeMove, mustMarshal(t, xoxo.NewMove(1, 1)))
+	h.Step()
+	if state := lastState(t, h, p2); len(state.State.Moves) != 1 || !state.YourTurn {
+		t.Errorf("expected 1 move, got: %s", state.State)
+	}
+	if n := len(h.Received(p1, xoxo.OpCodeError)) + len(h.Received(p1, xoxo.OpCodeDelta)); n != 0 {
+		t.Errorf("expected no errors or deltas for version 1 client, got: %d", n)
+	}
+	msgs := h.Received(p1, xoxo.OpCodeState)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 states, got: %d", len(msgs))
+	}
+	for i, msg := range msgs {
+		// the version 1 client decodes states strictly
+		var state struct {
+			ActivePlayer *v1TestPlayer `json:"active_player,omitempty"`
+			OtherPlayer  *v1TestPlayer `json:"other_player,omitempty"`
+			State        *struct {
+				Cells            [][]int        `json:"cells,omitempty"`
+				PlayerTurn       int            `json:"player_turn"`
+				Players          []v1TestPlayer `json:"players"`
+				Winner           xoxo.Winner    `json:"winner,omitempty"`
+				Draw             bool           `json:"draw,omitempty"`
+				RematchCountdown int            `json:"rematch_countdown,omitempty"`
+			} `json:"state,omitempty"`
+			YourTurn bool `json:"your_turn"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(msg.Data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&state); err != nil {
+			t.Fatalf("state %d expected no error, got: %v", i, err)
+		}
+		if state.State == nil || len(state.State.Players) != 2 || state.YourTurn != (i == 0) {
+			t.Errorf("state %d expected version 1 state, got: %s", i, msg.Data)
+		}
+	}
+	// version 1 clients only play the default variant
+	h, err = matchtest.New(context.Background(), "match", match{}, map[string]interface{}{
+		"variant": "4x4x3",
+	}, matchtest.WithLogf(t.Logf))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := h.Join(p1, nil); err == nil {
+		t.Errorf("expected version 1 client to be rejected from variant 4x4x3")
+	}
+}
+
+// v1TestPlayer is a player as decoded by version 1 clients.
+type v1TestPlayer struct {
+	Node      string `json:"node,omitempty"`
+	SessionId string `json:"session_id,omitempty"`
+	UserId    string `json:"user_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+func TestMatchBot(t *testing.T) {
+	h, err := matchtest.New(context.Background(), "match", match{}, map[string]interface{}{
+		"bot": string(xoxo.BotEasy),
+	}, matchtest.WithLogf(t.Logf))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	p1 := matchtest.NewPresence("1")
+	if err := h.Join(p1, metadata(xoxo.JSONCodec)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// the bot is seated without waiting
+	h.Step()
+	state := lastState(t, h, p1)
+	switch {
+	case state == nil || len(state.State.Players) != 2:
+		t.Fatalf("expected bot to be seated, got: %+v", state)
+	case !state.State.Players[1].Bot || !state.State.Casual || !state.YourTurn:
+		t.Fatalf("expected casual game against bot, got: %s", state.State)
+	}
+	for i := 0; state.State.Winner == 0 && !state.State.Draw; i++ {
+		v := state.State.Available()
+		move(t, h, p1, v[0][0], v[0][1])
+		h.Step()
+		state = lastState(t, h, p1)
+		if n := len(state.State.Moves); state.State.Winner == 0 && !state.State.Draw && (n != 2*(i+1) || !state.YourTurn) {
+			t.Fatalf("expected bot to move, got: %s", state.State)
+		}
+	}
+	if n := len(h.NK.Records[xoxo.LeaderboardRating]); n != 0 {
+		t.Errorf("expected no leaderboard records, got: %d", n)
+	}
+}
+
+func TestMatchSpectator(t *testing.T) {
+	h, p1, p2 := newMatch(t, match{}, map[string]interface{}{
+		"variant": "4x4x3",
+	})
+	spectators := []*matchtest.Presence{matchtest.NewPresence("s1"), matchtest.NewPresence("s2")}
+	for i, s := range spectators {
+		md := metadata([]xoxo.Codec{xoxo.JSONCodec, xoxo.BinaryCodec}[i])
+		md[xoxo.MetaSpectator] = "true"
+		if err := h.Join(s, md); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	move(t, h, p1, 0, 0)
+	move(t, h, p2, 3, 3)
+	h.Send(spectators[0], xoxo.OpCodeMove, moveData(t, h, p1, 1, 1))
+	h.Step()
+	exp := lastState(t, h, p1)
+	for _, s := range spectators {
+		if n := len(h.Received(s, xoxo.OpCodeDelta)); n == 0 {
+			t.Errorf("expected deltas for %s", s.UserId)
+		}
+		state := lastState(t, h, s)
+		switch {
+		case !state.Spectator || state.Seq != exp.Seq:
+			t.Errorf("expected spectator state %d, got: %+v", exp.Seq, state)
+		case state.State.String() != exp.State.String():
+			t.Errorf("expected %s, got: %s", exp.State, state.State)
+		case state.State.Players[0].SessionId != "":
+			t.Errorf("expected spectator state without session ids")
+		}
+	}
+	if _, ok := h.Last(spectators[0], xoxo.OpCodeError); !ok {
+		t.Errorf("expected error for spectator move")
+	}
+}
+
+func TestMatchAbandon(t *testing.T) {
+	h, p1, p2 := newMatch(t, match{reconnectGrace: 5}, nil)
+	move(t, h, p1, 0, 0)
+	h.Leave(p2)
+	leave := h.Tick
+	if state := lastState(t, h, p1); !state.State.Players[1].Disconnected || state.State.Winner != 0 {
+		t.Fatalf("expected disconnected player 2, got: %+v", state.State.Players)
+	}
+	h.Run(5)
+	if state := lastState(t, h, p1); state.State.Winner != 0 {
+		t.Fatalf("expected no winner before tick %d, got: %s", leave+5, state.State)
+	}
+	h.Run(2)
+	if state := lastState(t, h, p1); state.State.Winner != 1 || state.State.Reason != xoxo.ReasonAbandoned || !state.State.Finished {
+		t.Errorf("expected player 1 to win by abandonment, got: %s", state.State)
+	}
+	if h.Run(10) {
+		t.Errorf("expected match to terminate")
+	}
+}
+
+func TestMatchLeave(t *testing.T) {
+	// without a reconnect grace, leaving abandons the game
+	h, p1, p2 := newMatch(t, match{}, nil)
+	move(t, h, p1, 0, 0)
+	h.Leave(p2)
+	if state := lastState(t, h, p1); state.State.Winner != 1 || state.State.Reason != xoxo.ReasonAbandoned || !state.State.Finished {
+		t.Fatalf("expected player 1 to win by abandonment, got: %s", state.State)
+	}
+	h.Step()
+	if n := len(h.NK.Records[xoxo.LeaderboardRating]); n != 2 {
+		t.Errorf("expected 2 leaderboard records, got: %d", n)
+	}
+	objs, _, err := h.NK.StorageList(h.Ctx, p2.UserId, p2.UserId, historyCollection, 10, "")
+	if err != nil || len(objs) != 1 {
+		t.Errorf("expected 1 match record, got: %d %v", len(objs), err)
+	}
+}
+
+func TestMatchEmpty(t *testing.T) {
+	h, err := matchtest.New(context.Background(), "match", match{}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if h.Run(emptyWait) != true {
+		t.Fatalf("expected match to wait for players")
+	}
+	if h.Step() {
+		t.Errorf("expected unjoined match to terminate")
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	type props = map[string]interface{}
+	tests := []struct {
+		a, b    props
+		variant string
+		bestOf  int
+		err     bool
+	}{
+		{nil, nil, "3x3x3", 0, false},
+		{props{xoxo.PropVariant: "4x4x3", xoxo.PropBestOf: 3.0}, props{xoxo.PropVariant: "4x4x3", xoxo.PropBestOf: 3.0}, "4x4x3", 3, false},
+		{props{xoxo.PropVariant: "4x4x3"}, props{xoxo.PropVariant: "5x5x4"}, "", 0, true},
+		{props{xoxo.PropVariant: "3x3x3"}, nil, "3x3x3", 0, false},
+		{props{xoxo.PropVariant: "3x3"}, nil, "", 0, true},
+		{props{xoxo.PropBestOf: 3.0}, props{xoxo.PropBestOf: 5.0}, "", 0, true},
+		{props{xoxo.PropBestOf: 3.0}, nil, "", 0, true},
+		{props{xoxo.PropRegion: "eu"}, props{xoxo.PropRegion: "us"}, "", 0, true},
+		{props{xoxo.PropBot: "easy"}, props{xoxo.PropBot: "hard"}, "3x3x3", 0, false},
+		{props{xoxo.PropBot: "easy"}, nil, "3x3x3", 0, false},
+		{props{xoxo.PropBot: "expert"}, nil, "", 0, true},
+		{props{xoxo.PropRating: 1500.0, xoxo.PropRatingSpread: 100.0}, props{xoxo.PropRating: 1550.0}, "3x3x3", 0, false},
+		{props{xoxo.PropRating: 1500.0, xoxo.PropRatingSpread: 100.0}, props{xoxo.PropRating: 1650.0}, "", 0, true},
+		{props{xoxo.PropRating: 1500.0}, props{xoxo.PropRatingSpread: 100.0}, "", 0, true},
+	}
+	for i, test := range tests {
+		variant, bestOf, err := compatible([]runtime.MatchmakerEntry{
+			matchtest.NewMatchmakerEntry("1", test.a),
+			matchtest.NewMatchmakerEntry("2", test.b),
+		})
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error", i)
+		case !test.err && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		case !test.err && (variant.String() != test.variant || bestOf != test.bestOf):
+			t.Errorf("test %d expected %s best of %d, got: %s %d", i, test.variant, test.bestOf, variant, bestOf)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		code, err := newCode()
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(code) != codeLen || strings.Trim(code, codeAlphabet) != "" {
+			t.Fatalf("expected %d characters from %s, got: %q", codeLen, codeAlphabet, code)
+		}
+		if s, ok := normalizeCode(strings.ToLower(code[:3] + "-" + code[3:])); !ok || s != code {
+			t.Fatalf("expected %s, got: %q %t", code, s, ok)
+		}
+	}
+	for _, code := range []string{"", "ABC23", "ABC2345", "ABC10O"} {
+		if _, ok := normalizeCode(code); ok {
+			t.Errorf("expected %q to be invalid", code)
+		}
+	}
+}
+
+func TestPrivate(t *testing.T) {
+	ctx, logger := context.Background(), matchtest.NewLogger(t.Logf)
+	nk := matchtest.NewNakamaModule()
+	nk.RegisterMatch("xoxo", match{})
+	tests := []struct {
+		payload string
+		casual  bool
+	}{
+		{`{"best_of":3}`, true},
+		{`{"rated":true}`, false},
+	}
+	var codes []string
+	for i, test := range tests {
+		buf, err := rpcCreatePrivate(ctx, logger, nil, nk, test.payload)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var res xoxo.CreatePrivateResponse
+		if err := json.Unmarshal([]byte(buf), &res); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		h := nk.Matches[res.MatchId]
+		switch {
+		case h == nil:
+			t.Fatalf("test %d expected match %s to be created", i, res.MatchId)
+		case res.Casual != test.casual || h.State.(*matchState).state.Casual != test.casual:
+			t.Errorf("test %d expected casual %t, got: %t %t", i, test.casual, res.Casual, h.State.(*matchState).state.Casual)
+		}
+		codes = append(codes, res.Code)
+		buf, err = rpcJoinCode(ctx, logger, nil, nk, `{"code":"`+strings.ToLower(res.Code)+`"}`)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		var join xoxo.JoinCodeResponse
+		if err := json.Unmarshal([]byte(buf), &join); err != nil || join.MatchId != res.MatchId {
+			t.Errorf("test %d expected match %s, got: %q %v", i, res.MatchId, join.MatchId, err)
+		}
+	}
+	// codes of terminated matches are not found
+	for _, h := range nk.Matches {
+		h.Terminate(0)
+	}
+	for _, code := range append(codes, "ABC") {
+		if _, err := rpcJoinCode(ctx, logger, nil, nk, `{"code":"`+code+`"}`); err == nil {
+			t.Errorf("expected code %s to return error", code)
+		}
+	}
+}
+
+// newMatch creates a match joined by two players.
+func newMatch(t *testing.T, m match, params map[string]interface{}) (*matchtest.Harness, *matchtest.Presence, *matchtest.Presence) {
+	t.Helper()
+	h, err := matchtest.New(context.Background(), "match", m, params, matchtest.WithLogf(t.Logf))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	p1, p2 := matchtest.NewPresence("1"), matchtest.NewPresence("2")
+	for _, p := range []*matchtest.Presence{p1, p2} {
+		if err := h.Join(p, metadata(xoxo.JSONCodec)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	h.Step()
+	return h, p1, p2
+}
+
+// metadata returns the join metadata for a client using the codec.
+func metadata(codec xoxo.Codec) map[string]string {
+	return map[string]string{
+		xoxo.MetaProtocol: strconv.Itoa(xoxo.ProtocolVersion),
+		xoxo.MetaCodec:    codec.Name(),
+	}
+}
+
+// lastState returns the presence's current state, built from the full states
+// and deltas sent to the presence.
+func lastState(t *testing.T, h *matchtest.Harness, p *matchtest.Presence) *xoxo.MatchState {
+	t.Helper()
+	codec := h.State.(*matchState).peer(p).codec
+	var state *xoxo.MatchState
+	for _, m := range h.Received(p, 0) {
+		switch m.OpCode {
+		case xoxo.OpCodeState:
+			state = new(xoxo.MatchState)
+			if err := codec.Unmarshal(m.Data, state); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		case xoxo.OpCodeDelta:
+			d := new(xoxo.Delta)
+			if err := codec.Unmarshal(m.Data, d); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			var err error
+			if state, err = d.Apply(state); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+	}
+	return state
+}
+
+// playWin plays a game won by p1.
+func playWin(t *testing.T, h *matchtest.Harness, p1, p2 *matchtest.Presence) {
+	t.Helper()
+	for i, m := range [][]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}} {
+		p := p1
+		if i%2 == 1 {
+			p = p2
+		}
+		move(t, h, p, m[0], m[1])
+	}
+}
+
+// move sends the move for the presence, and runs a tick.
+func move(t *testing.T, h *matchtest.Harness, p *matchtest.Presence, row, col int) {
+	t.Helper()
+	n := len(h.Received(p, xoxo.OpCodeError))
+	h.Send(p, xoxo.OpCodeMove, moveData(t, h, p, row, col))
+	h.Step()
+	if len(h.Received(p, xoxo.OpCodeError)) != n {
+		t.Fatalf("expected no error for move %d, %d by %s", row, col, p.UserId)
+	}
+}
+
+// moveData returns the encoded move, made against the presence's current
+// state.
+func moveData(t *testing.T, h *matchtest.Harness, p *matchtest.Presence, row, col int) []byte {
+	t.Helper()
+	m := xoxo.NewMove(row, col)
+	if state := lastState(t, h, p); state != nil {
+		m.Seq = state.Seq
+	}
+	return mustMarshal(t, m)
+}
+
+func send(t *testing.T, h *matchtest.Harness, p *matchtest.Presence, opCode int64, v interface{}) {
+	t.Helper()
+	h.Send(p, opCode, mustMarshal(t, v))
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	buf, err := xoxo.JSONCodec.Marshal(v)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	return buf
+}
@@ -0,0 +1,159 @@
+package nkxoxo
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// codeAlphabet is the join code alphabet, without easily confused
+// characters.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// codeLen is the join code length.
+const codeLen = 6
+
+// label is the match label.
+type label struct {
+	Code string `json:"code,omitempty"`
+}
+
+// newCode generates a random join code.
+func newCode() (string, error) {
+	var sb strings.Builder
+	size := big.NewInt(int64(len(codeAlphabet)))
+	for i := 0; i < codeLen; i++ {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", fmt.Errorf("unable to generate code: %w", err)
+		}
+		sb.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// normalizeCode normalizes a user entered join code, returning false when
+// the code is not valid.
+func normalizeCode(code string) (string, bool) {
+	code = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+	if len(code) != codeLen {
+		return "", false
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(codeAlphabet, c) {
+			return "", false
+		}
+	}
+	return code, true
+}
+
+// findCode returns the id of the running match with the join code.
+func findCode(ctx context.Context, nk runtime.NakamaModule, code string) (string, error) {
+	matches, err := nk.MatchList(ctx, 1, true, "", nil, nil, "+label.code:"+code)
+	if err != nil {
+		return "", fmt.Errorf("unable to list matches: %w", err)
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	return matches[0].GetMatchId(), nil
+}
+
+// rpcCreatePrivate creates a private match, returning its match id and join
+// code. The match is casual unless the request asks for a rated match.
+func rpcCreatePrivate(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req xoxo.CreatePrivateRequest
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", runtime.NewError("invalid request", 3)
+		}
+	}
+	variant := xoxo.DefaultVariant
+	if req.Variant != "" {
+		var err error
+		if variant, err = xoxo.ParseVariant(req.Variant); err != nil {
+			return "", runtime.NewError(err.Error(), 3)
+		}
+	}
+	if err := xoxo.ValidBestOf(req.BestOf); err != nil {
+		return "", runtime.NewError(err.Error(), 3)
+	}
+	// retry on the unlikely collision with a running match
+	for i := 0; i < 5; i++ {
+		code, err := newCode()
+		if err != nil {
+			return "", err
+		}
+		switch matchId, err := findCode(ctx, nk, code); {
+		case err != nil:
+			logger.
+				WithField("error", err).
+				Error("unable to find code")
+			return "", runtime.NewError("unable to create match", 13)
+		case matchId != "":
+			continue
+		}
+		matchId, err := nk.MatchCreate(ctx, "xoxo", map[string]interface{}{
+			"variant": variant.String(),
+			"best_of": req.BestOf,
+			"code":    code,
+			"rated":   req.Rated,
+		})
+		if err != nil {
+			logger.
+				WithField("error", err).
+				Error("unable to create private match")
+			return "", runtime.NewError("unable to create match", 13)
+		}
+		logger.
+			WithField("match_id", matchId).
+			WithField("code", code).
+			Debug("created private match")
+		buf, err := json.Marshal(xoxo.CreatePrivateResponse{
+			MatchId: matchId,
+			Code:    code,
+			Casual:  !req.Rated,
+		})
+		if err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	return "", runtime.NewError("unable to generate code", 13)
+}
+
+// rpcJoinCode resolves a join code to the private match's id.
+func rpcJoinCode(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req xoxo.JoinCodeRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", runtime.NewError("invalid request", 3)
+	}
+	code, ok := normalizeCode(req.Code)
+	if !ok {
+		return "", runtime.NewError("invalid code", 3)
+	}
+	matchId, err := findCode(ctx, nk, code)
+	switch {
+	case err != nil:
+		logger.
+			WithField("error", err).
+			Error("unable to find code")
+		return "", runtime.NewError("unable to find match", 13)
+	case matchId == "":
+		return "", runtime.NewError("match not found", 5)
+	}
+	buf, err := json.Marshal(xoxo.JoinCodeResponse{
+		MatchId: matchId,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,165 @@
+package nkxoxo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/ascii8/xoxo-go/rating"
+	"github.com/ascii8/xoxo-go/xoxo"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// rating storage.
+const (
+	ratingCollection = "ratings"
+	ratingKey        = "rating"
+)
+
+// storedRating is a player rating read from storage.
+type storedRating struct {
+	xoxo.PlayerRating
+	version string
+}
+
+// readRatings reads the stored ratings for the users, returning the default
+// rating for users without a stored rating.
+func readRatings(ctx context.Context, nk runtime.NakamaModule, userIds ...string) ([]*storedRating, error) {
+	reads := make([]*runtime.StorageRead, len(userIds))
+	for i, userId := range userIds {
+		reads[i] = &runtime.StorageRead{
+			Collection: ratingCollection,
+			Key:        ratingKey,
+			UserID:     userId,
+		}
+	}
+	objs, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ratings: %w", err)
+	}
+	ratings := make([]*storedRating, len(userIds))
+	for i, userId := range userIds {
+		ratings[i] = &storedRating{
+			PlayerRating: xoxo.PlayerRating{
+				UserId: userId,
+				Rating: rating.New(),
+			},
+		}
+		for _, obj := range objs {
+			if obj.GetUserId() != userId {
+				continue
+			}
+			if err := json.Unmarshal([]byte(obj.GetValue()), &ratings[i].PlayerRating); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal rating for %s: %w", userId, err)
+			}
+			ratings[i].UserId, ratings[i].version = userId, obj.GetVersion()
+		}
+	}
+	return ratings, nil
+}
+
+// recordResult updates both players' ratings with the result of the ended
+// game in the state. Casual games and games against a bot are not rated.
+func recordResult(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, state *xoxo.State) error {
+	if len(state.Players) != 2 || state.Players[0].Bot || state.Players[1].Bot || state.Casual {
+		return nil
+	}
+	ratings, err := readRatings(ctx, nk, state.Players[0].UserId, state.Players[1].UserId)
+	if err != nil {
+		return err
+	}
+	score := rating.Draw
+	switch state.Winner {
+	case 1:
+		score = rating.Win
+		ratings[0].Wins++
+		ratings[1].Losses++
+	case 2:
+		score = rating.Loss
+		ratings[0].Losses++
+		ratings[1].Wins++
+	default:
+		ratings[0].Draws++
+		ratings[1].Draws++
+	}
+	ratings[0].Rating, ratings[1].Rating = rating.Match(ratings[0].Rating, ratings[1].Rating, score)
+	writes := make([]*runtime.StorageWrite, 2)
+	for i, r := range ratings {
+		r.Games++
+		buf, err := json.Marshal(r.PlayerRating)
+		if err != nil {
+			return fmt.Errorf("unable to marshal rating for %s: %w", r.UserId, err)
+		}
+		writes[i] = &runtime.StorageWrite{
+			Collection:      ratingCollection,
+			Key:             ratingKey,
+			UserID:          r.UserId,
+			Value:           string(buf),
+			Version:         r.version,
+			PermissionRead:  2,
+			PermissionWrite: 0,
+		}
+	}
+	if _, err := nk.StorageWrite(ctx, writes); err != nil {
+		return fmt.Errorf("unable to write ratings: %w", err)
+	}
+	for i, r := range ratings {
+		if _, err := nk.LeaderboardRecordWrite(
+			ctx,
+			xoxo.LeaderboardRating,
+			r.UserId,
+			state.Players[i].Username,
+			int64(math.Round(r.Rating.Rating)),
+			int64(r.Games),
+			nil,
+			nil,
+		); err != nil {
+			return fmt.Errorf("unable to write leaderboard record for %s: %w", r.UserId, err)
+		}
+	}
+	logger.
+		WithField("ratings", ratings).
+		Debug("recorded result")
+	return nil
+}
+
+// rpcRatings retrieves the ratings for the requested users, or for the
+// calling user.
+func rpcRatings(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req xoxo.RatingsRequest
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", runtime.NewError("invalid request", 3)
+		}
+	}
+	if len(req.UserIds) == 0 {
+		userId, _ := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+		if userId == "" {
+			return "", runtime.NewError("no user id", 3)
+		}
+		req.UserIds = []string{userId}
+	}
+	if len(req.UserIds) > 100 {
+		return "", runtime.NewError("too many user ids", 3)
+	}
+	ratings, err := readRatings(ctx, nk, req.UserIds...)
+	if err != nil {
+		logger.
+			WithField("error", err).
+			Error("unable to read ratings")
+		return "", runtime.NewError("unable to read ratings", 13)
+	}
+	res := xoxo.RatingsResponse{
+		Ratings: make([]xoxo.PlayerRating, len(ratings)),
+	}
+	for i, r := range ratings {
+		res.Ratings[i] = r.PlayerRating
+	}
+	buf, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,156 @@
+// Package rating implements the Glicko-2 player rating system.
+//
+// See: http://www.glicko.net/glicko/glicko2.pdf
+package rating
+
+import (
+	"math"
+)
+
+// Glicko-2 defaults.
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+	// DefaultTau is the default system constant, constraining the change in
+	// volatility over time.
+	DefaultTau = 0.5
+)
+
+// scale is the Glicko to Glicko-2 scale factor.
+const scale = 173.7178
+
+// epsilon is the convergence tolerance of the volatility iteration.
+const epsilon = 0.000001
+
+// Scores.
+const (
+	Loss = 0.0
+	Draw = 0.5
+	Win  = 1.0
+)
+
+// Rating is a Glicko-2 rating.
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// New creates a new rating with the default values.
+func New() Rating {
+	return Rating{
+		Rating:     DefaultRating,
+		Deviation:  DefaultDeviation,
+		Volatility: DefaultVolatility,
+	}
+}
+
+// Result is the result of a game against an opponent.
+type Result struct {
+	Opponent Rating
+	// Score is Win, Draw or Loss.
+	Score float64
+}
+
+// Update returns the rating after a rating period with the results, using
+// the default system constant.
+func (r Rating) Update(results ...Result) Rating {
+	return r.UpdateTau(DefaultTau, results...)
+}
+
+// UpdateTau returns the rating after a rating period with the results, using
+// the system constant tau.
+func (r Rating) UpdateTau(tau float64, results ...Result) Rating {
+	mu, phi, sigma := r.scaled()
+	// a player that did not play only increases their deviation
+	if len(results) == 0 {
+		return unscaled(mu, math.Sqrt(phi*phi+sigma*sigma), sigma)
+	}
+	// estimated variance and improvement
+	var v, delta float64
+	for _, res := range results {
+		oMu, oPhi, _ := res.Opponent.scaled()
+		g := g(oPhi)
+		e := e(mu, oMu, g)
+		v += g * g * e * (1 - e)
+		delta += g * (res.Score - e)
+	}
+	v = 1 / v
+	delta *= v
+	// new volatility
+	sigma = volatility(delta, phi, v, sigma, tau)
+	// new deviation and rating
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * (delta / v)
+	return unscaled(mu, phi, sigma)
+}
+
+// Expected returns the expected score of r against the opponent.
+func (r Rating) Expected(opponent Rating) float64 {
+	mu, _, _ := r.scaled()
+	oMu, oPhi, _ := opponent.scaled()
+	return e(mu, oMu, g(oPhi))
+}
+
+// scaled returns the rating on the Glicko-2 scale.
+func (r Rating) scaled() (float64, float64, float64) {
+	return (r.Rating - DefaultRating) / scale, r.Deviation / scale, r.Volatility
+}
+
+// Match returns the ratings of a and b after a single game, where score is
+// a's score.
+func Match(a, b Rating, score float64) (Rating, Rating) {
+	return a.Update(Result{Opponent: b, Score: score}),
+		b.Update(Result{Opponent: a, Score: 1 - score})
+}
+
+// unscaled returns the rating from the Glicko-2 scale.
+func unscaled(mu, phi, sigma float64) Rating {
+	return Rating{
+		Rating:     mu*scale + DefaultRating,
+		Deviation:  phi * scale,
+		Volatility: sigma,
+	}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func e(mu, oMu, g float64) float64 {
+	return 1 / (1 + math.Exp(-g*(mu-oMu)))
+}
+
+// volatility determines the new volatility using the Illinois algorithm.
+func volatility(delta, phi, v, sigma, tau float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(tau*tau)
+	}
+	A, B := a, 0.0
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,63 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	// example from the Glicko-2 paper
+	r := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	res := r.Update(
+		Result{Opponent: Rating{Rating: 1400, Deviation: 30, Volatility: 0.06}, Score: Win},
+		Result{Opponent: Rating{Rating: 1550, Deviation: 100, Volatility: 0.06}, Score: Loss},
+		Result{Opponent: Rating{Rating: 1700, Deviation: 300, Volatility: 0.06}, Score: Loss},
+	)
+	t.Logf("rating: %+v", res)
+	check(t, "rating", 1464.06, res.Rating, 0.01)
+	check(t, "deviation", 151.52, res.Deviation, 0.01)
+	check(t, "volatility", 0.05999, res.Volatility, 0.00001)
+}
+
+func TestUpdateNoGames(t *testing.T) {
+	r := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	res := r.Update()
+	check(t, "rating", 1500, res.Rating, 0.0001)
+	check(t, "deviation", 200.2714, res.Deviation, 0.0001)
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		score float64
+		a, b  float64
+	}{
+		{Win, 1662.31, 1337.69},
+		{Draw, 1500, 1500},
+		{Loss, 1337.69, 1662.31},
+	}
+	for _, test := range tests {
+		a, b := Match(New(), New(), test.score)
+		t.Logf("score: %v a: %+v b: %+v", test.score, a, b)
+		check(t, "a", test.a, a.Rating, 0.01)
+		check(t, "b", test.b, b.Rating, 0.01)
+		if a.Deviation >= DefaultDeviation || b.Deviation >= DefaultDeviation {
+			t.Errorf("expected deviation to decrease, got: %f, %f", a.Deviation, b.Deviation)
+		}
+	}
+}
+
+func TestExpected(t *testing.T) {
+	a, b := New(), New()
+	check(t, "expected", 0.5, a.Expected(b), 0.0001)
+	a.Rating = 1800
+	if e := a.Expected(b); e <= 0.5 {
+		t.Errorf("expected higher rated player to be favored, got: %f", e)
+	}
+}
+
+func check(t *testing.T, name string, exp, v, tolerance float64) {
+	t.Helper()
+	if math.Abs(exp-v) > tolerance {
+		t.Errorf("expected %s %f, got: %f", name, exp, v)
+	}
+}
@@ -0,0 +1,493 @@
+// Package solver is a negamax solver for xoxo positions.
+package solver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+// Outcome is the game-theoretic outcome of a position, for the player to
+// move.
+type Outcome int
+
+// Outcome values.
+const (
+	Unknown Outcome = iota
+	Loss
+	Draw
+	Win
+)
+
+// String satisfies the fmt.Stringer interface.
+func (o Outcome) String() string {
+	switch o {
+	case Loss:
+		return "loss"
+	case Draw:
+		return "draw"
+	case Win:
+		return "win"
+	}
+	return "unknown"
+}
+
+// Result is the result of solving a position.
+type Result struct {
+	// Player is the player to move.
+	Player int
+	// Outcome is the outcome with best play, for the player to move. Unknown
+	// when the search was depth limited and did not reach the end of the game.
+	Outcome Outcome
+	// Score is the negamax score for the player to move. Wins and losses are
+	// scored closer to 0 the further away they are.
+	Score int
+	// Plies is the number of plies until the win or loss.
+	Plies int
+	// Moves are all the optimal moves.
+	Moves []xoxo.Move
+	// Nodes is the number of nodes searched.
+	Nodes int
+}
+
+// Solver is a negamax solver with alpha-beta pruning and a transposition
+// table, that can be shared between multiple goroutines.
+type Solver struct {
+	maxDepth int
+	maxSize  int
+	r        *rand.Rand
+
+	variant xoxo.Variant
+	keys    [2][]uint64
+	turn    uint64
+	lines   [][]int
+	table   map[uint64]entry
+	nodes   int
+
+	rw sync.Mutex
+	rm sync.Mutex
+}
+
+// New creates a new solver.
+func New(opts ...Option) *Solver {
+	s := &Solver{
+		maxSize: 1 << 22,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	if s.r == nil {
+		s.r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s
+}
+
+// Solve solves the state, returning the value and all optimal moves for the
+// player to move.
+func Solve(ctx context.Context, state *xoxo.State, opts ...Option) (*Result, error) {
+	return New(opts...).Solve(ctx, state)
+}
+
+// Solve solves the state, returning the value and all optimal moves for the
+// player to move.
+func (s *Solver) Solve(ctx context.Context, state *xoxo.State) (*Result, error) {
+	switch {
+	case state.Winner != 0:
+		return nil, fmt.Errorf("match already won by player %d", state.Winner)
+	case state.Draw:
+		return nil, fmt.Errorf("match is a draw")
+	case state.PlayerTurn != 1 && state.PlayerTurn != 2:
+		return nil, fmt.Errorf("invalid player turn")
+	}
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	s.init(state.Variant)
+	b := s.newBoard(state)
+	depth := len(b.cells) - b.count
+	if s.maxDepth != 0 && s.maxDepth < depth {
+		depth = s.maxDepth
+	}
+	s.nodes = 0
+	res := &Result{
+		Player: b.player,
+		Score:  -infinity,
+	}
+	// search each root move with a full window, to find all optimal moves
+	for _, i := range s.order(b, -1) {
+		score, err := s.root(ctx, b, i, depth)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case score > res.Score:
+			res.Score, res.Moves = score, []xoxo.Move{xoxo.NewMove(i/b.cols, i%b.cols)}
+		case score == res.Score:
+			res.Moves = append(res.Moves, xoxo.NewMove(i/b.cols, i%b.cols))
+		}
+	}
+	switch {
+	case res.Score > winBound:
+		res.Outcome, res.Plies = Win, winScore-res.Score
+	case res.Score < -winBound:
+		res.Outcome, res.Plies = Loss, winScore+res.Score
+	case depth == len(b.cells)-b.count:
+		res.Outcome = Draw
+	}
+	res.Nodes = s.nodes
+	return res, nil
+}
+
+var _ xoxo.Strategy = (*Solver)(nil)
+
+// ChooseMove satisfies the xoxo.Strategy interface, choosing a random
+// optimal move.
+func (s *Solver) ChooseMove(ctx context.Context, state *xoxo.State) (xoxo.Move, error) {
+	res, err := s.Solve(ctx, state)
+	if err != nil {
+		return xoxo.Move{}, err
+	}
+	if len(res.Moves) == 0 {
+		return xoxo.Move{}, fmt.Errorf("no available moves")
+	}
+	s.rm.Lock()
+	defer s.rm.Unlock()
+	return res.Moves[s.r.Intn(len(res.Moves))], nil
+}
+
+// root searches the root move i.
+func (s *Solver) root(ctx context.Context, b *board, i, depth int) (int, error) {
+	b.play(i)
+	defer b.undo(i)
+	if b.wins(i) {
+		return winScore - 1, nil
+	}
+	score, err := s.negamax(ctx, b, depth-1, 1, -infinity, infinity)
+	return -score, err
+}
+
+// negamax searches the board, returning the score for the player to move.
+func (s *Solver) negamax(ctx context.Context, b *board, depth, ply, alpha, beta int) (int, error) {
+	s.nodes++
+	if s.nodes%4096 == 0 {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	switch {
+	case b.count == len(b.cells):
+		return 0, nil
+	case depth == 0:
+		return s.evaluate(b), nil
+	}
+	alpha0, best := alpha, -1
+	if e, ok := s.table[b.hash]; ok {
+		best = e.best
+		if depth <= e.depth {
+			score := fromTable(e.score, ply)
+			switch {
+			case e.flag == exact:
+				return score, nil
+			case e.flag == lower && alpha < score:
+				alpha = score
+			case e.flag == upper && score < beta:
+				beta = score
+			}
+			if beta <= alpha {
+				return score, nil
+			}
+		}
+	}
+	// a win in 1 is always best
+	moves := s.order(b, best)
+	for _, i := range moves {
+		b.play(i)
+		win := b.wins(i)
+		b.undo(i)
+		if win {
+			return winScore - ply - 1, nil
+		}
+	}
+	score := -infinity
+	for _, i := range moves {
+		b.play(i)
+		v, err := s.negamax(ctx, b, depth-1, ply+1, -beta, -alpha)
+		b.undo(i)
+		if err != nil {
+			return 0, err
+		}
+		if v = -v; v > score {
+			score, best = v, i
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if beta <= alpha {
+			break
+		}
+	}
+	flag := exact
+	switch {
+	case score <= alpha0:
+		flag = upper
+	case beta <= score:
+		flag = lower
+	}
+	if len(s.table) >= s.maxSize {
+		s.table = make(map[uint64]entry)
+	}
+	s.table[b.hash] = entry{
+		score: toTable(score, ply),
+		depth: depth,
+		flag:  flag,
+		best:  best,
+	}
+	return score, nil
+}
+
+// evaluate heuristically scores the board for the player to move, by
+// counting the marks in lines not blocked by the other player.
+func (s *Solver) evaluate(b *board) int {
+	score := 0
+	for _, line := range s.lines {
+		var n [3]int
+		for _, i := range line {
+			n[b.cells[i]]++
+		}
+		switch {
+		case n[1] != 0 && n[2] == 0:
+			score += weight(n[1])
+		case n[2] != 0 && n[1] == 0:
+			score -= weight(n[2])
+		}
+	}
+	if b.player == 2 {
+		score = -score
+	}
+	switch {
+	case score > heuristicBound:
+		return heuristicBound
+	case score < -heuristicBound:
+		return -heuristicBound
+	}
+	return score
+}
+
+// order returns the available moves, ordered with the best move first, then
+// by distance to the center of the board.
+func (s *Solver) order(b *board, best int) []int {
+	moves := make([]int, 0, len(b.cells)-b.count)
+	if best != -1 && b.cells[best] == 0 {
+		moves = append(moves, best)
+	}
+	for _, i := range b.center {
+		if i != best && b.cells[i] == 0 {
+			moves = append(moves, i)
+		}
+	}
+	return moves
+}
+
+// init initializes the solver for the variant.
+func (s *Solver) init(variant xoxo.Variant) {
+	if s.table != nil && s.variant == variant {
+		return
+	}
+	n := variant.Rows * variant.Cols
+	r := rand.New(rand.NewSource(int64(n)))
+	s.variant = variant
+	s.keys = [2][]uint64{make([]uint64, n), make([]uint64, n)}
+	for i := 0; i < n; i++ {
+		s.keys[0][i], s.keys[1][i] = r.Uint64(), r.Uint64()
+	}
+	// the table is kept between solves, so the hash includes the player to
+	// move for positions reached with either player moving first
+	s.turn = r.Uint64()
+	s.lines = nil
+	for _, line := range variant.Lines() {
+		v := make([]int, len(line))
+		for j, c := range line {
+			v[j] = c[0]*variant.Cols + c[1]
+		}
+		s.lines = append(s.lines, v)
+	}
+	s.table = make(map[uint64]entry)
+}
+
+// newBoard creates a board for the state.
+func (s *Solver) newBoard(state *xoxo.State) *board {
+	rows, cols := state.Rows, state.Cols
+	b := &board{
+		rows:   rows,
+		cols:   cols,
+		k:      state.K,
+		keys:   s.keys,
+		turn:   s.turn,
+		cells:  make([]int, rows*cols),
+		player: state.PlayerTurn,
+	}
+	if b.player == 2 {
+		b.hash = b.turn
+	}
+	for i := 0; i < rows*cols; i++ {
+		if p := state.Cells[i/cols][i%cols]; p == 1 || p == 2 {
+			b.cells[i] = p
+			b.hash ^= b.keys[p-1][i]
+			b.count++
+		}
+	}
+	b.center = make([]int, rows*cols)
+	dist := make([]int, rows*cols)
+	for i := range b.center {
+		dr, dc := 2*(i/cols)-(rows-1), 2*(i%cols)-(cols-1)
+		b.center[i], dist[i] = i, dr*dr+dc*dc
+	}
+	// insertion sort, stable by index
+	for i := 1; i < len(b.center); i++ {
+		for j := i; j > 0 && dist[b.center[j]] < dist[b.center[j-1]]; j-- {
+			b.center[j], b.center[j-1] = b.center[j-1], b.center[j]
+		}
+	}
+	return b
+}
+
+// board is a flattened board.
+type board struct {
+	rows   int
+	cols   int
+	k      int
+	keys   [2][]uint64
+	turn   uint64
+	cells  []int
+	center []int
+	count  int
+	player int
+	hash   uint64
+}
+
+// play plays the player to move at cell i.
+func (b *board) play(i int) {
+	b.cells[i] = b.player
+	b.hash ^= b.keys[b.player-1][i] ^ b.turn
+	b.count++
+	b.player = 3 - b.player
+}
+
+// undo undoes the move at cell i.
+func (b *board) undo(i int) {
+	b.player = 3 - b.player
+	b.count--
+	b.hash ^= b.keys[b.player-1][i] ^ b.turn
+	b.cells[i] = 0
+}
+
+// wins determines if the mark at cell i is part of k in a row.
+func (b *board) wins(i int) bool {
+	p, row, col := b.cells[i], i/b.cols, i%b.cols
+	for _, d := range directions {
+		n := 1
+		for _, sign := range [2]int{1, -1} {
+			r, c := row+sign*d[0], col+sign*d[1]
+			for 0 <= r && r < b.rows && 0 <= c && c < b.cols && b.cells[r*b.cols+c] == p {
+				n, r, c = n+1, r+sign*d[0], c+sign*d[1]
+			}
+		}
+		if b.k <= n {
+			return true
+		}
+	}
+	return false
+}
+
+// entry is a transposition table entry.
+type entry struct {
+	score int
+	depth int
+	flag  int
+	best  int
+}
+
+// entry flags.
+const (
+	exact = iota
+	lower
+	upper
+)
+
+// scores.
+const (
+	infinity       = 1 << 30
+	winScore       = 1 << 24
+	winBound       = winScore - 1<<12
+	heuristicBound = 1 << 20
+)
+
+// toTable converts a score relative to the root to a score relative to the
+// node at ply.
+func toTable(score, ply int) int {
+	switch {
+	case score > winBound:
+		return score + ply
+	case score < -winBound:
+		return score - ply
+	}
+	return score
+}
+
+// fromTable converts a score relative to the node at ply to a score relative
+// to the root.
+func fromTable(score, ply int) int {
+	switch {
+	case score > winBound:
+		return score - ply
+	case score < -winBound:
+		return score + ply
+	}
+	return score
+}
+
+// weight is the heuristic weight of n marks in an open line.
+func weight(n int) int {
+	if n > 8 {
+		n = 8
+	}
+	return 1 << (2 * n)
+}
+
+// directions are the row, col steps of the lines on the board.
+var directions = [4][2]int{
+	{0, 1},
+	{1, 0},
+	{1, 1},
+	{-1, 1},
+}
+
+// Option is a solver option.
+type Option func(*Solver)
+
+// WithMaxDepth is a solver option to limit the search depth in plies. Positions
+// beyond the depth are scored heuristically.
+func WithMaxDepth(maxDepth int) Option {
+	return func(s *Solver) {
+		s.maxDepth = maxDepth
+	}
+}
+
+// WithRand is a solver option to set the random source used by ChooseMove to
+// choose between optimal moves.
+func WithRand(r *rand.Rand) Option {
+	return func(s *Solver) {
+		s.r = r
+	}
+}
+
+// WithMaxTableSize is a solver option to set the maximum number of
+// transposition table entries.
+func WithMaxTableSize(maxSize int) Option {
+	return func(s *Solver) {
+		s.maxSize = maxSize
+	}
+}
@@ -0,0 +1,177 @@
+package solver
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		variant string
+		moves   [][2]int
+		outcome Outcome
+		plies   int
+		exp     []xoxo.Move
+	}{
+		{"3x3x3", nil, Draw, 0, nil},
+		{"3x3x3", [][2]int{{0, 0}, {1, 1}, {0, 1}}, Draw, 0, []xoxo.Move{xoxo.NewMove(0, 2)}},
+		{"3x3x3", [][2]int{{0, 0}, {1, 1}, {0, 1}, {2, 2}}, Win, 1, []xoxo.Move{xoxo.NewMove(0, 2)}},
+		{"3x3x3", [][2]int{{0, 0}, {0, 1}}, Win, 5, nil},
+		{"3x3x3", [][2]int{{0, 0}, {0, 1}, {1, 1}}, Loss, 4, []xoxo.Move{xoxo.NewMove(2, 2)}},
+		{"4x4x3", nil, Win, 0, nil},
+		{"3x4x3", nil, Win, 0, nil},
+		{"1x3x2", nil, Win, 3, []xoxo.Move{xoxo.NewMove(0, 1)}},
+		{"1x4x3", nil, Draw, 0, nil},
+	}
+	for i, v := range tests {
+		test := v
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			state := newState(t, test.variant, test.moves)
+			res, err := Solve(context.Background(), state)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			t.Logf("state: %s outcome: %s score: %d plies: %d moves: %v nodes: %d", state, res.Outcome, res.Score, res.Plies, res.Moves, res.Nodes)
+			if res.Outcome != test.outcome {
+				t.Errorf("expected outcome %s, got: %s", test.outcome, res.Outcome)
+			}
+			if test.plies != 0 && res.Plies != test.plies {
+				t.Errorf("expected plies %d, got: %d", test.plies, res.Plies)
+			}
+			if test.exp != nil && !reflect.DeepEqual(res.Moves, test.exp) {
+				t.Errorf("expected moves %v, got: %v", test.exp, res.Moves)
+			}
+			if len(res.Moves) == 0 {
+				t.Errorf("expected at least one move")
+			}
+		})
+	}
+}
+
+func TestSolveAllMoves(t *testing.T) {
+	// every opening move in 3x3 Tic-Tac-Toe is a draw
+	res, err := Solve(context.Background(), newState(t, "3x3x3", nil))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(res.Moves) != 9 {
+		t.Errorf("expected 9 moves, got: %d", len(res.Moves))
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	state := newState(t, "15x15x5", [][2]int{{7, 7}, {7, 8}, {8, 8}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := Solve(ctx, state, WithMaxDepth(2))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	t.Logf("outcome: %s score: %d moves: %v nodes: %d", res.Outcome, res.Score, res.Moves, res.Nodes)
+	if res.Outcome != Unknown {
+		t.Errorf("expected outcome %s, got: %s", Unknown, res.Outcome)
+	}
+	if len(res.Moves) == 0 {
+		t.Fatalf("expected at least one move")
+	}
+	if err := state.Move(state.Players[state.PlayerTurn-1].UserId, res.Moves[0]); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+func TestSolveCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Solve(ctx, newState(t, "5x5x4", nil)); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestSolveEnded(t *testing.T) {
+	state := newState(t, "3x3x3", [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}})
+	if _, err := Solve(context.Background(), state); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestChooseMove(t *testing.T) {
+	ctx := context.Background()
+	s := New(WithRand(rand.New(rand.NewSource(0))))
+	random := xoxo.NewRandom(rand.New(rand.NewSource(1)))
+	// the solver never loses, moving first or second
+	for i := 0; i < 20; i++ {
+		state := newState(t, "3x3x3", nil)
+		strategies := []xoxo.Strategy{s, random}
+		if i%2 == 1 {
+			strategies[0], strategies[1] = random, s
+		}
+		for state.Winner == 0 && !state.Draw {
+			p := state.PlayerTurn
+			move, err := strategies[p-1].ChooseMove(ctx, state.Copy())
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if err := state.Move(state.Players[p-1].UserId, move); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+		if w := state.Winner.Int(); w != 0 && strategies[w-1] != s {
+			t.Errorf("game %d: expected solver not to lose, got: %s", i, state)
+		}
+	}
+}
+
+func TestSolveFirstMover(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	if _, err := s.Solve(ctx, newState(t, "3x3x3", nil)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// the same cells with player 2 having moved first, as after a rematch
+	state := newState(t, "3x3x3", nil)
+	state.PlayerTurn = 2
+	for i, move := range [][2]int{{1, 1}, {0, 0}} {
+		if err := state.Move(strconv.Itoa(1-i), xoxo.NewMove(move[0], move[1])); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	res, err := s.Solve(ctx, state)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp, err := Solve(ctx, state)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if res.Outcome != Draw || !reflect.DeepEqual(res.Moves, exp.Moves) {
+		t.Errorf("expected draw with moves %v, got: %s %v", exp.Moves, res.Outcome, res.Moves)
+	}
+}
+
+func newState(t *testing.T, variant string, moves [][2]int) *xoxo.State {
+	v, err := xoxo.ParseVariant(variant)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	state, err := xoxo.NewVariantState(v)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := state.Add("", "", strconv.Itoa(i), ""); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	for i, move := range moves {
+		if err := state.Move(strconv.Itoa(i%2), xoxo.NewMove(move[0], move[1])); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	return state
+}
@@ -0,0 +1,42 @@
+package solver
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+// Strategies are the strategy names accepted by ParseStrategy.
+var Strategies = []string{"random", "greedy", "minimax", "mcts"}
+
+// ParseStrategy creates the named strategy, using r for its random choices.
+// The minimax search depth and the MCTS iterations can be set with a suffix,
+// as in "minimax:4" or "mcts:500".
+func ParseStrategy(name string, r *rand.Rand) (xoxo.Strategy, error) {
+	typ, param, _ := strings.Cut(name, ":")
+	n := 0
+	if param != "" {
+		var err error
+		if n, err = strconv.Atoi(param); err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid strategy %q", name)
+		}
+	}
+	switch {
+	case typ == "random" && param == "":
+		return xoxo.NewRandom(r), nil
+	case typ == "greedy" && param == "":
+		return xoxo.NewGreedy(r), nil
+	case typ == "minimax":
+		opts := []Option{WithMaxDepth(n)}
+		if r != nil {
+			opts = append(opts, WithRand(r))
+		}
+		return New(opts...), nil
+	case typ == "mcts":
+		return xoxo.NewMCTS(n, r), nil
+	}
+	return nil, fmt.Errorf("invalid strategy %q", name)
+}
@@ -0,0 +1,600 @@
+package xoxo
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// binaryVersion is the version of the binary encoding, prefixed to each
+// message.
+const binaryVersion = 1
+
+// binaryCodec is the binary codec. Messages are encoded using the protobuf
+// wire format, with signed integers zigzag encoded, and with unknown fields
+// ignored when decoding.
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string {
+	return "binary"
+}
+
+func (binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{b: []byte{binaryVersion}}
+	switch x := v.(type) {
+	case Move:
+		e.move(x)
+	case *Move:
+		e.move(*x)
+	case Response:
+		e.bool(1, x.Accept)
+	case *Response:
+		e.bool(1, x.Accept)
+	case *MatchState:
+		e.matchState(x)
+	case *Delta:
+		e.delta(x)
+	case *MatchError:
+		e.matchError(x)
+	default:
+		return nil, fmt.Errorf("binary codec: unsupported type %T", v)
+	}
+	return e.b, nil
+}
+
+func (binaryCodec) Unmarshal(buf []byte, v interface{}) error {
+	switch {
+	case len(buf) == 0:
+		return fmt.Errorf("binary codec: empty message")
+	case buf[0] != binaryVersion:
+		return fmt.Errorf("binary codec: unsupported version %d", buf[0])
+	}
+	buf = buf[1:]
+	switch x := v.(type) {
+	case *Move:
+		return decodeMove(buf, x)
+	case *Response:
+		return decodeFields(buf, func(num protowire.Number, f field) error {
+			if num == 1 {
+				x.Accept = f.bool()
+			}
+			return nil
+		})
+	case *MatchState:
+		return decodeMatchState(buf, x)
+	case *Delta:
+		return decodeDelta(buf, x)
+	case *MatchError:
+		return decodeMatchError(buf, x)
+	}
+	return fmt.Errorf("binary codec: unsupported type %T", v)
+}
+
+// encoder encodes fields, omitting zero values.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int(num protowire.Number, v int64) {
+	if v == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, protowire.EncodeZigZag(v))
+}
+
+func (e *encoder) bool(num protowire.Number, v bool) {
+	if !v {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, 1)
+}
+
+func (e *encoder) string(num protowire.Number, v string) {
+	if v == "" {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendString(e.b, v)
+}
+
+// ints encodes v as packed integers. Encodes nil and empty v differently, so
+// that a nil slice can be decoded as nil.
+func (e *encoder) ints(num protowire.Number, v []int) {
+	if v == nil {
+		return
+	}
+	var b []byte
+	for _, i := range v {
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(i)))
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, b)
+}
+
+func (e *encoder) bools(num protowire.Number, v []bool) {
+	if v == nil {
+		return
+	}
+	b := make([]byte, len(v))
+	for i, x := range v {
+		if x {
+			b[i] = 1
+		}
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, b)
+}
+
+// message encodes the fields added by f as an embedded message.
+func (e *encoder) message(num protowire.Number, f func(*encoder)) {
+	m := new(encoder)
+	f(m)
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, m.b)
+}
+
+func (e *encoder) move(m Move) {
+	e.int(1, int64(m.Row))
+	e.int(2, int64(m.Col))
+	e.int(3, m.Seq)
+}
+
+func (e *encoder) player(p Player) {
+	e.string(1, p.Node)
+	e.string(2, p.SessionId)
+	e.string(3, p.UserId)
+	e.string(4, p.Username)
+	e.bool(5, p.Bot)
+	e.bool(6, p.Disconnected)
+}
+
+func (e *encoder) series(num protowire.Number, s *Series) {
+	if s == nil {
+		return
+	}
+	e.message(num, func(e *encoder) {
+		e.int(1, int64(s.BestOf))
+		e.int(2, int64(s.Games))
+		e.ints(3, s.Wins)
+		e.int(4, int64(s.Draws))
+	})
+}
+
+func (e *encoder) state(s *State) {
+	e.int(1, int64(s.Rows))
+	e.int(2, int64(s.Cols))
+	e.int(3, int64(s.K))
+	// cells are flattened, rows first
+	if s.Cells != nil {
+		cells := make([]int, 0, s.Rows*s.Cols)
+		for _, row := range s.Cells {
+			cells = append(cells, row...)
+		}
+		e.ints(4, cells)
+	}
+	e.int(5, int64(s.PlayerTurn))
+	for _, p := range s.Players {
+		e.message(6, func(e *encoder) {
+			e.player(p)
+		})
+	}
+	e.int(7, int64(s.Winner))
+	e.bool(8, s.Draw)
+	e.string(9, string(s.Reason))
+	e.int(10, int64(s.RematchCountdown))
+	if tc := s.TimeControl; tc != nil {
+		e.message(11, func(e *encoder) {
+			e.int(1, int64(tc.Game))
+			e.int(2, int64(tc.Turn))
+			e.int(3, int64(tc.Increment))
+		})
+	}
+	e.ints(12, s.Clocks)
+	e.int(13, int64(s.TurnClock))
+	for _, m := range s.Moves {
+		e.message(14, func(e *encoder) {
+			e.move(m)
+		})
+	}
+	e.bool(15, s.Casual)
+	e.int(16, int64(s.Takeback))
+	e.int(17, int64(s.DrawOffer))
+	e.bools(18, s.Rematch)
+	e.series(19, s.Series)
+	e.bool(20, s.Finished)
+}
+
+// matchState encodes the match state. The active and other players are not
+// encoded, as they are determined by the state's players.
+func (e *encoder) matchState(m *MatchState) {
+	if m.State != nil {
+		e.message(1, func(e *encoder) {
+			e.state(m.State)
+		})
+	}
+	e.bool(2, m.YourTurn)
+	e.bool(3, m.Spectator)
+	e.int(4, m.Seq)
+}
+
+func (e *encoder) delta(d *Delta) {
+	e.int(1, d.Seq)
+	e.int(2, d.Base)
+	for _, c := range d.Cells {
+		e.message(3, func(e *encoder) {
+			e.int(1, int64(c.Row))
+			e.int(2, int64(c.Col))
+			e.int(3, int64(c.Player))
+		})
+	}
+	e.int(4, int64(d.Undo))
+	for _, m := range d.Moves {
+		e.message(5, func(e *encoder) {
+			e.move(m)
+		})
+	}
+	e.int(6, int64(d.PlayerTurn))
+	e.bool(7, d.YourTurn)
+	e.int(8, int64(d.Winner))
+	e.bool(9, d.Draw)
+	e.string(10, string(d.Reason))
+	e.int(11, int64(d.RematchCountdown))
+	e.ints(12, d.Clocks)
+	e.int(13, int64(d.TurnClock))
+	e.int(14, int64(d.Takeback))
+	e.int(15, int64(d.DrawOffer))
+	e.bools(16, d.Rematch)
+	e.series(17, d.Series)
+	e.bool(18, d.Finished)
+}
+
+func (e *encoder) matchError(m *MatchError) {
+	e.int(1, m.OpCode)
+	e.int(2, int64(m.Code))
+	e.string(3, m.Message)
+	if m.Move != nil {
+		e.message(4, func(e *encoder) {
+			e.move(*m.Move)
+		})
+	}
+}
+
+// field is a decoded field value.
+type field struct {
+	typ protowire.Type
+	v   uint64
+	b   []byte
+}
+
+func (f field) int() int {
+	return int(f.int64())
+}
+
+func (f field) int64() int64 {
+	if f.typ != protowire.VarintType {
+		return 0
+	}
+	return protowire.DecodeZigZag(f.v)
+}
+
+func (f field) bool() bool {
+	return f.typ == protowire.VarintType && f.v != 0
+}
+
+func (f field) string() string {
+	return string(f.b)
+}
+
+func (f field) ints() ([]int, error) {
+	v := make([]int, 0, len(f.b))
+	for b := f.b; len(b) != 0; {
+		x, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		v, b = append(v, int(protowire.DecodeZigZag(x))), b[n:]
+	}
+	return v, nil
+}
+
+func (f field) bools() []bool {
+	v := make([]bool, len(f.b))
+	for i, x := range f.b {
+		v[i] = x != 0
+	}
+	return v
+}
+
+// decodeFields decodes the fields in buf, calling f for each field. Groups
+// and fixed size fields are skipped.
+func decodeFields(buf []byte, f func(protowire.Number, field) error) error {
+	for len(buf) != 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return fmt.Errorf("binary codec: %w", protowire.ParseError(n))
+		}
+		buf = buf[n:]
+		fld := field{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			fld.v, n = protowire.ConsumeVarint(buf)
+		case protowire.BytesType:
+			fld.b, n = protowire.ConsumeBytes(buf)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return fmt.Errorf("binary codec: field %d: %w", num, protowire.ParseError(n))
+		}
+		buf = buf[n:]
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := f(num, fld); err != nil {
+			return fmt.Errorf("binary codec: field %d: %w", num, err)
+		}
+	}
+	return nil
+}
+
+func decodeMove(buf []byte, m *Move) error {
+	return decodeFields(buf, func(num protowire.Number, f field) error {
+		switch num {
+		case 1:
+			m.Row = f.int()
+		case 2:
+			m.Col = f.int()
+		case 3:
+			m.Seq = f.int64()
+		}
+		return nil
+	})
+}
+
+func decodeSeries(buf []byte) (*Series, error) {
+	s := new(Series)
+	err := decodeFields(buf, func(num protowire.Number, f field) error {
+		var err error
+		switch num {
+		case 1:
+			s.BestOf = f.int()
+		case 2:
+			s.Games = f.int()
+		case 3:
+			s.Wins, err = f.ints()
+		case 4:
+			s.Draws = f.int()
+		}
+		return err
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case len(s.Wins) != 2:
+		return nil, fmt.Errorf("%d series wins", len(s.Wins))
+	}
+	return s, nil
+}
+
+func decodeState(buf []byte, s *State) error {
+	var cells []int
+	err := decodeFields(buf, func(num protowire.Number, f field) error {
+		var err error
+		switch num {
+		case 1:
+			s.Rows = f.int()
+		case 2:
+			s.Cols = f.int()
+		case 3:
+			s.K = f.int()
+		case 4:
+			cells, err = f.ints()
+		case 5:
+			s.PlayerTurn = f.int()
+		case 6:
+			var p Player
+			err = decodeFields(f.b, func(num protowire.Number, f field) error {
+				switch num {
+				case 1:
+					p.Node = f.string()
+				case 2:
+					p.SessionId = f.string()
+				case 3:
+					p.UserId = f.string()
+				case 4:
+					p.Username = f.string()
+				case 5:
+					p.Bot = f.bool()
+				case 6:
+					p.Disconnected = f.bool()
+				}
+				return nil
+			})
+			s.Players = append(s.Players, p)
+		case 7:
+			s.Winner = Winner(f.int())
+		case 8:
+			s.Draw = f.bool()
+		case 9:
+			s.Reason = Reason(f.string())
+		case 10:
+			s.RematchCountdown = f.int()
+		case 11:
+			tc := new(TimeControl)
+			err = decodeFields(f.b, func(num protowire.Number, f field) error {
+				switch num {
+				case 1:
+					tc.Game = f.int()
+				case 2:
+					tc.Turn = f.int()
+				case 3:
+					tc.Increment = f.int()
+				}
+				return nil
+			})
+			s.TimeControl = tc
+		case 12:
+			s.Clocks, err = f.ints()
+		case 13:
+			s.TurnClock = f.int()
+		case 14:
+			var m Move
+			err = decodeMove(f.b, &m)
+			s.Moves = append(s.Moves, m)
+		case 15:
+			s.Casual = f.bool()
+		case 16:
+			s.Takeback = f.int()
+		case 17:
+			s.DrawOffer = f.int()
+		case 18:
+			s.Rematch = f.bools()
+		case 19:
+			s.Series, err = decodeSeries(f.b)
+		case 20:
+			s.Finished = f.bool()
+		}
+		return err
+	})
+	switch {
+	case err != nil:
+		return err
+	case s.Rows < 0 || MaxBoardSize < s.Rows || s.Cols < 0 || MaxBoardSize < s.Cols:
+		return fmt.Errorf("binary codec: invalid %dx%d board", s.Rows, s.Cols)
+	case len(s.Players) > 2:
+		return fmt.Errorf("binary codec: %d players", len(s.Players))
+	case s.PlayerTurn < -1 || 2 < s.PlayerTurn || s.Winner < 0 || 2 < s.Winner:
+		return fmt.Errorf("binary codec: invalid player turn %d or winner %d", s.PlayerTurn, s.Winner)
+	case len(s.Clocks) != 0 && len(s.Clocks) != 2:
+		return fmt.Errorf("binary codec: %d clocks", len(s.Clocks))
+	case len(s.Rematch) != 0 && len(s.Rematch) != 2:
+		return fmt.Errorf("binary codec: %d rematch votes", len(s.Rematch))
+	case cells == nil && s.Rows == 0 && s.Cols == 0:
+		return nil
+	case s.Rows == 0 || s.Cols == 0 || len(cells) != s.Rows*s.Cols:
+		return fmt.Errorf("binary codec: %d cells for %dx%d board", len(cells), s.Rows, s.Cols)
+	}
+	s.Cells = make([][]int, s.Rows)
+	for i := range s.Cells {
+		s.Cells[i] = cells[i*s.Cols : (i+1)*s.Cols]
+	}
+	return nil
+}
+
+func decodeMatchState(buf []byte, m *MatchState) error {
+	err := decodeFields(buf, func(num protowire.Number, f field) error {
+		switch num {
+		case 1:
+			m.State = new(State)
+			return decodeState(f.b, m.State)
+		case 2:
+			m.YourTurn = f.bool()
+		case 3:
+			m.Spectator = f.bool()
+		case 4:
+			m.Seq = f.int64()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if m.State != nil && len(m.State.Players) == 2 {
+		m.ActivePlayer, m.OtherPlayer = &m.State.Players[0], &m.State.Players[1]
+		if m.State.PlayerTurn == 2 {
+			m.ActivePlayer, m.OtherPlayer = m.OtherPlayer, m.ActivePlayer
+		}
+	}
+	return nil
+}
+
+func decodeDelta(buf []byte, d *Delta) error {
+	err := decodeFields(buf, func(num protowire.Number, f field) error {
+		var err error
+		switch num {
+		case 1:
+			d.Seq = f.int64()
+		case 2:
+			d.Base = f.int64()
+		case 3:
+			var c Cell
+			err = decodeFields(f.b, func(num protowire.Number, f field) error {
+				switch num {
+				case 1:
+					c.Row = f.int()
+				case 2:
+					c.Col = f.int()
+				case 3:
+					c.Player = f.int()
+				}
+				return nil
+			})
+			d.Cells = append(d.Cells, c)
+		case 4:
+			d.Undo = f.int()
+		case 5:
+			var m Move
+			err = decodeMove(f.b, &m)
+			d.Moves = append(d.Moves, m)
+		case 6:
+			d.PlayerTurn = f.int()
+		case 7:
+			d.YourTurn = f.bool()
+		case 8:
+			d.Winner = Winner(f.int())
+		case 9:
+			d.Draw = f.bool()
+		case 10:
+			d.Reason = Reason(f.string())
+		case 11:
+			d.RematchCountdown = f.int()
+		case 12:
+			d.Clocks, err = f.ints()
+		case 13:
+			d.TurnClock = f.int()
+		case 14:
+			d.Takeback = f.int()
+		case 15:
+			d.DrawOffer = f.int()
+		case 16:
+			d.Rematch = f.bools()
+		case 17:
+			d.Series, err = decodeSeries(f.b)
+		case 18:
+			d.Finished = f.bool()
+		}
+		return err
+	})
+	switch {
+	case err != nil:
+		return err
+	case d.Undo < 0:
+		return fmt.Errorf("binary codec: invalid undo %d", d.Undo)
+	case d.PlayerTurn < -1 || 2 < d.PlayerTurn || d.Winner < 0 || 2 < d.Winner:
+		return fmt.Errorf("binary codec: invalid player turn %d or winner %d", d.PlayerTurn, d.Winner)
+	case len(d.Clocks) != 0 && len(d.Clocks) != 2:
+		return fmt.Errorf("binary codec: %d clocks", len(d.Clocks))
+	case len(d.Rematch) != 0 && len(d.Rematch) != 2:
+		return fmt.Errorf("binary codec: %d rematch votes", len(d.Rematch))
+	}
+	return nil
+}
+
+func decodeMatchError(buf []byte, m *MatchError) error {
+	return decodeFields(buf, func(num protowire.Number, f field) error {
+		switch num {
+		case 1:
+			m.OpCode = f.int64()
+		case 2:
+			m.Code = ErrorCode(f.int())
+		case 3:
+			m.Message = f.string()
+		case 4:
+			m.Move = new(Move)
+			return decodeMove(f.b, m.Move)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package xoxo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Bot plays a session's games, choosing its moves with a strategy.
+type Bot struct {
+	sess     Session
+	strategy Strategy
+	games    int
+	joinOpts []JoinOption
+	logf     func(string, ...interface{})
+	result   func(int, *MatchState)
+}
+
+// NewBot creates a bot for the session.
+func NewBot(sess Session, strategy Strategy, opts ...BotOption) *Bot {
+	b := &Bot{
+		sess:     sess,
+		strategy: strategy,
+		logf:     func(string, ...interface{}) {},
+	}
+	for _, o := range opts {
+		o(b)
+	}
+	return b
+}
+
+// Run joins a match, and plays its games until the configured number of games
+// have been played or the match is finished, voting for a rematch between
+// games. The bot does not leave the match.
+func (b *Bot) Run(ctx context.Context) error {
+	if err := b.sess.Join(ctx, b.joinOpts...); err != nil {
+		return err
+	}
+	for i := 0; b.games < 1 || i < b.games; i++ {
+		state, err := b.Play(ctx)
+		switch {
+		case err != nil:
+			return err
+		case state == nil:
+			return nil
+		}
+		if b.result != nil {
+			b.result(i+1, state)
+		}
+		if state.State.Finished || (0 < b.games && b.games <= i+1) {
+			return nil
+		}
+		if err := b.sess.Rematch(ctx, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Play waits for the session's next game to start, and plays it, returning
+// the final state of the game. Returns a nil state when the match finishes
+// without another game.
+func (b *Bot) Play(ctx context.Context) (*MatchState, error) {
+	if !b.sess.Ready(ctx) {
+		return nil, ctx.Err()
+	}
+	for b.sess.Next(ctx) {
+		if x, ok := b.sess.(interface{ Err() *MatchError }); ok {
+			if err := x.Err(); err != nil {
+				b.logf("Bot: move rejected: %v", err)
+			}
+		}
+		state := b.sess.State()
+		move, err := b.strategy.ChooseMove(ctx, state.State.Copy())
+		if err != nil {
+			return nil, fmt.Errorf("unable to choose move: %w", err)
+		}
+		b.logf("Bot: player %d moving %d, %d", state.State.PlayerTurn, move.Row, move.Col)
+		if err := b.sess.Move(ctx, move.Row-1, move.Col-1); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	state := b.sess.State()
+	if state == nil {
+		return nil, fmt.Errorf("no active match")
+	}
+	return state, nil
+}
+
+// BotOption is a bot option.
+type BotOption func(*Bot)
+
+// WithBotGames is a bot option to set the number of games to play, or 0 to
+// play until the match is finished.
+func WithBotGames(games int) BotOption {
+	return func(b *Bot) {
+		b.games = games
+	}
+}
+
+// WithBotJoin is a bot option to set the options used to join a match.
+func WithBotJoin(opts ...JoinOption) BotOption {
+	return func(b *Bot) {
+		b.joinOpts = opts
+	}
+}
+
+// WithBotLogf is a bot option to set the log func.
+func WithBotLogf(logf func(string, ...interface{})) BotOption {
+	return func(b *Bot) {
+		b.logf = logf
+	}
+}
+
+// WithBotResult is a bot option to set a func called with the final state of
+// each game played.
+func WithBotResult(result func(int, *MatchState)) BotOption {
+	return func(b *Bot) {
+		b.result = result
+	}
+}
@@ -0,0 +1,41 @@
+package xoxo_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestBot(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	l, err := xoxo.NewLocal(xoxo.ModeAI, xoxo.WithLocalAI(xoxo.NewRandom(rand.New(rand.NewSource(0)))))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var games int
+	b := xoxo.NewBot(
+		l, xoxo.NewGreedy(rand.New(rand.NewSource(1))),
+		xoxo.WithBotGames(3),
+		xoxo.WithBotJoin(xoxo.WithJoinBestOf(3)),
+		xoxo.WithBotLogf(t.Logf),
+		xoxo.WithBotResult(func(game int, state *xoxo.MatchState) {
+			games++
+			if game != games {
+				t.Errorf("expected game %d, got: %d", games, game)
+			}
+			if state.State.Winner == 0 && !state.State.Draw {
+				t.Errorf("game %d: expected game over, got: %s", game, state.State)
+			}
+		}),
+	)
+	if err := b.Run(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if games < 2 || 3 < games {
+		t.Errorf("expected 2 or 3 games, got: %d", games)
+	}
+}
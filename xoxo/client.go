@@ -3,9 +3,11 @@ package xoxo
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/ascii8/nakama-go"
 	"github.com/google/uuid"
@@ -20,14 +22,30 @@ type Client struct {
 	username string
 	logf     func(string, ...interface{})
 	persist  bool
+	botLevel BotLevel
+	codec    Codec
 
-	ticketId string
-	matchId  string
-	state    *MatchState
-	waiting  bool
+	// the connection and match fields are guarded by rw. The match state is
+	// an immutable snapshot, replaced and never modified once set, and the
+	// handlers are called without holding rw.
+	ticketId  string
+	joining   bool
+	matchId   string
+	spectator bool
+	state     *MatchState
+	waiting   bool
+	merr      *MatchError
+	changed   chan struct{}
 
 	rw sync.RWMutex
 
+	events chan Event
+	notify chan struct{}
+	done   chan struct{}
+	queue  []Event
+
+	em sync.Mutex
+
 	connectHandler              func(context.Context)
 	disconnectHandler           func(context.Context, error)
 	errorHandler                func(context.Context, *nakama.ErrorMsg)
@@ -46,7 +64,9 @@ type Client struct {
 func NewClient(opts ...Option) *Client {
 	cl := &Client{
 		logf:    func(string, ...interface{}) {},
+		codec:   JSONCodec,
 		waiting: true,
+		changed: make(chan struct{}),
 	}
 	cl.cl = nakama.New(
 		nakama.WithURL("http://127.0.0.1:7352"),
@@ -80,111 +100,168 @@ func Dial(ctx context.Context, opts ...Option) (*Client, error) {
 }
 
 func (cl *Client) Open(ctx context.Context) error {
-	conn := cl.conn
-	if conn == nil {
-		opts := []nakama.ConnOption{
-			nakama.WithConnHandler(cl),
-			nakama.WithConnPersist(cl.persist),
-		}
-		if cl.debug {
-			opts = append(opts, nakama.WithConnFormat("json"))
-		}
-		var err error
-		if conn, err = cl.cl.NewConn(ctx, opts...); err != nil {
-			return err
-		}
-		cl.conn = conn
+	if cl.connection() != nil {
+		return nil
 	}
+	opts := []nakama.ConnOption{
+		nakama.WithConnHandler(cl),
+		nakama.WithConnPersist(cl.persist),
+	}
+	if cl.debug {
+		opts = append(opts, nakama.WithConnFormat("json"))
+	}
+	conn, err := cl.cl.NewConn(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	cl.rw.Lock()
+	if cl.conn != nil {
+		// opened concurrently
+		cl.rw.Unlock()
+		return conn.CloseWithStopErr(true, true, nil)
+	}
+	cl.conn = conn
+	cl.rw.Unlock()
 	return nil
 }
 
+// connection returns the client's connection.
+func (cl *Client) connection() *nakama.Conn {
+	cl.rw.RLock()
+	defer cl.rw.RUnlock()
+	return cl.conn
+}
+
 func (cl *Client) Close() error {
 	_ = cl.Leave(context.Background())
 	cl.rw.Lock()
-	defer cl.rw.Unlock()
-	if cl.conn != nil {
-		_ = cl.conn.CloseWithStopErr(true, true, nil)
-	}
+	conn := cl.conn
 	cl.state = nil
+	cl.rw.Unlock()
+	// the connection's handlers use the lock
+	if conn != nil {
+		_ = conn.CloseWithStopErr(true, true, nil)
+	}
+	cl.stopEvents()
 	return nil
 }
 
 func (cl *Client) Connected() bool {
-	conn := cl.conn
+	conn := cl.connection()
 	return conn != nil && conn.Connected()
 }
 
+// State returns a snapshot of the match state, or nil when there is no active
+// match. The snapshot is shared and must not be modified; use its State's Copy
+// to derive a state.
 func (cl *Client) State() *MatchState {
+	cl.rw.RLock()
+	defer cl.rw.RUnlock()
 	return cl.state
 }
 
 func (cl *Client) MatchId() string {
+	cl.rw.RLock()
+	defer cl.rw.RUnlock()
 	return cl.matchId
 }
 
+// Spectating returns true when the client is spectating the match.
+func (cl *Client) Spectating() bool {
+	cl.rw.RLock()
+	defer cl.rw.RUnlock()
+	return cl.spectator
+}
+
+// Ready waits until a game is ready to be played, returning false when the
+// context is done or the match is finished.
 func (cl *Client) Ready(ctx context.Context) bool {
-	ch := make(chan bool, 1)
-	go func() {
-		defer close(ch)
-		for {
-			if state := cl.state; state != nil && state.State.RematchCountdown == 0 {
-				ch <- true
-				return
-			}
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(50 * time.Millisecond):
-			}
+	for {
+		cl.rw.RLock()
+		state, changed := cl.state, cl.changed
+		cl.rw.RUnlock()
+		switch {
+		case state == nil:
+		case state.State.Finished:
+			return false
+		case state.State.RematchCountdown == 0:
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
 		}
-	}()
-	select {
-	case <-ctx.Done():
-		return false
-	case res := <-ch:
-		return res
 	}
 }
 
+// Err returns the error for the client's last message rejected by the match,
+// if any. Cleared by the next move.
+func (cl *Client) Err() *MatchError {
+	cl.rw.RLock()
+	defer cl.rw.RUnlock()
+	return cl.merr
+}
+
 func (cl *Client) Next(ctx context.Context) bool {
-	ch := make(chan bool, 1)
-	go func() {
-		defer close(ch)
-		for {
-			cl.rw.RLock()
-			waiting, state := cl.waiting, cl.state
-			cl.rw.RUnlock()
-			switch {
-			case waiting || state == nil:
-			case state.State.Winner != 0,
-				state.State.Draw,
-				state.State.RematchCountdown != 0:
-				return
-			case state.YourTurn:
-				ch <- true
-				return
-			}
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(50 * time.Millisecond):
-			}
+	for {
+		cl.rw.RLock()
+		waiting, state, changed := cl.waiting, cl.state, cl.changed
+		cl.rw.RUnlock()
+		switch {
+		case waiting || state == nil:
+		case state.State.Winner != 0,
+			state.State.Draw,
+			state.State.RematchCountdown != 0:
+			return false
+		case state.YourTurn:
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
 		}
-	}()
-	select {
-	case <-ctx.Done():
-		return false
-	case res := <-ch:
-		return res
 	}
 }
 
+// change notifies waiters of a change to the client's state. Must be called
+// while holding the write lock.
+func (cl *Client) change() {
+	close(cl.changed)
+	cl.changed = make(chan struct{})
+}
+
 func (cl *Client) AuthHandler(ctx context.Context, nakamaClient *nakama.Client) error {
 	return nakamaClient.AuthenticateDevice(ctx, cl.userId, true, cl.username)
 }
 
 func (cl *Client) ConnectHandler(ctx context.Context) {
 	cl.logf("Connect!")
+	cl.rw.RLock()
+	conn, matchId, spectator := cl.conn, cl.matchId, cl.spectator
+	cl.rw.RUnlock()
+	// reclaim seat after reconnecting
+	if matchId != "" {
+		cl.logf("Connect: rejoining match %q", matchId)
+		conn.MatchJoinAsync(ctx, matchId, cl.metadata(spectator), func(msg *nakama.MatchMsg, err error) {
+			if err == nil {
+				cl.logf("Connect: rejoined match %q", matchId)
+				return
+			}
+			cl.logf("error: Connect: unable to rejoin match %q: %v", matchId, err)
+			cl.rw.Lock()
+			lost := cl.matchId == matchId
+			if lost {
+				cl.matchId, cl.spectator, cl.waiting, cl.state = "", false, true, nil
+				cl.change()
+			}
+			cl.rw.Unlock()
+			if lost && cl.stateHandler != nil {
+				cl.stateHandler(ctx)
+			}
+		})
+	}
 	if cl.connectHandler != nil {
 		cl.connectHandler(ctx)
 	}
@@ -192,6 +269,10 @@ func (cl *Client) ConnectHandler(ctx context.Context) {
 
 func (cl *Client) DisconnectHandler(ctx context.Context, err error) {
 	cl.logf("Disconnect: %v", err)
+	cl.rw.RLock()
+	matchId := cl.matchId
+	cl.rw.RUnlock()
+	cl.emit(EventDisconnected, matchId, nil, err)
 	if cl.disconnectHandler != nil {
 		cl.disconnectHandler(ctx, err)
 	}
@@ -220,42 +301,144 @@ func (cl *Client) ChannelPresenceEventHandler(ctx context.Context, msg *nakama.C
 
 func (cl *Client) MatchDataHandler(ctx context.Context, msg *nakama.MatchDataMsg) {
 	cl.logf("MatchData: %+v", msg)
+	switch msg.OpCode {
+	case OpCodeError:
+		cl.matchError(ctx, msg)
+		return
+	case OpCodeDelta:
+		cl.delta(ctx, msg)
+		return
+	}
 	state := new(MatchState)
-	if err := state.Unmarshal(msg.Data); err != nil {
+	if err := cl.codec.Unmarshal(msg.Data, state); err != nil {
 		cl.logf("unable to unmarshal MatchData: %v", err)
 		state = nil
 	}
 	cl.rw.Lock()
-	defer cl.rw.Unlock()
 	prev := cl.state
-	cl.waiting, cl.state = state == nil, state
-	if cl.matchDataHandler != nil {
-		cl.matchDataHandler(ctx, msg)
+	// drop states older than the current state
+	if prev != nil && state != nil && state.Seq != 0 && state.Seq <= prev.Seq {
+		cl.rw.Unlock()
+		cl.logf("dropping stale state %d (%d)", state.Seq, prev.Seq)
+		return
+	}
+	notify := cl.setState(prev, state)
+	cl.rw.Unlock()
+	cl.dispatch(ctx, msg, notify)
+}
+
+// delta applies a delta sent by the match to the client's state, requesting
+// a full state from the match when a delta was missed.
+func (cl *Client) delta(ctx context.Context, msg *nakama.MatchDataMsg) {
+	d := new(Delta)
+	if err := cl.codec.Unmarshal(msg.Data, d); err != nil {
+		cl.logf("unable to unmarshal Delta: %v", err)
+		return
+	}
+	cl.rw.Lock()
+	prev, matchId, conn := cl.state, cl.matchId, cl.conn
+	switch {
+	case matchId == "":
+		cl.rw.Unlock()
+		return
+	case prev != nil && d.Seq <= prev.Seq:
+		cl.rw.Unlock()
+		cl.logf("dropping stale delta %d (%d)", d.Seq, prev.Seq)
+		return
 	}
-	if cl.stateHandler == nil {
+	state, err := d.Apply(prev)
+	if err != nil {
+		cl.rw.Unlock()
+		cl.logf("unable to apply delta, resyncing: %v", err)
+		go func() {
+			if err := conn.MatchDataSend(ctx, matchId, OpCodeResync, nil, true); err != nil {
+				cl.logf("unable to request resync: %v", err)
+			}
+		}()
 		return
 	}
+	notify := cl.setState(prev, state)
+	cl.rw.Unlock()
+	cl.dispatch(ctx, msg, notify)
+}
+
+// setState sets the client's state, notifying waiters and queuing events.
+// Returns true when the state handler should be notified of the change. Must
+// be called while holding the write lock.
+func (cl *Client) setState(prev, state *MatchState) bool {
+	cl.waiting, cl.state = state == nil, state
+	cl.change()
+	cl.emitState(cl.matchId, prev, state)
 	switch {
 	case prev == nil && state != nil,
-		prev != nil && state == nil,
-		prev.YourTurn != state.YourTurn,
-		prev.State.RematchCountdown != state.State.RematchCountdown,
-		state.State.Winner != 0,
-		state.State.Draw:
+		prev != nil && state == nil:
+		return true
+	case prev == nil:
+		return false
+	}
+	return prev.YourTurn != state.YourTurn ||
+		prev.State.Takeback != state.State.Takeback ||
+		prev.State.DrawOffer != state.State.DrawOffer ||
+		prev.State.Finished != state.State.Finished ||
+		state.Spectator ||
+		prev.State.RematchCountdown != state.State.RematchCountdown ||
+		state.State.Winner != 0 ||
+		state.State.Draw
+}
+
+// dispatch calls the match data handler with the message, and the state
+// handler when notify is true. Must be called without holding the lock, so
+// that the handlers can use the client.
+func (cl *Client) dispatch(ctx context.Context, msg *nakama.MatchDataMsg, notify bool) {
+	if cl.matchDataHandler != nil {
+		cl.matchDataHandler(ctx, msg)
+	}
+	if notify && cl.stateHandler != nil {
 		cl.stateHandler(ctx)
 	}
 }
 
+// matchError handles an error sent by the match for a rejected message,
+// clearing the waiting flag so that the move can be retried.
+func (cl *Client) matchError(ctx context.Context, msg *nakama.MatchDataMsg) {
+	merr := new(MatchError)
+	if err := cl.codec.Unmarshal(msg.Data, merr); err != nil {
+		cl.logf("unable to unmarshal MatchError: %v", err)
+		return
+	}
+	cl.logf("match error: %v", merr)
+	cl.rw.Lock()
+	cl.waiting, cl.merr = false, merr
+	cl.change()
+	cl.emit(EventError, cl.matchId, cl.state, merr)
+	cl.rw.Unlock()
+	cl.dispatch(ctx, msg, true)
+}
+
 func (cl *Client) MatchPresenceEventHandler(ctx context.Context, msg *nakama.MatchPresenceEventMsg) {
 	cl.logf("MatchPresenceEvent: %+v", msg)
-	if len(msg.Leaves) != 0 {
-		cl.rw.Lock()
-		cl.state = nil
-		cl.rw.Unlock()
-		if cl.stateHandler != nil {
-			cl.stateHandler(ctx)
+	cl.rw.Lock()
+	matchId, state := cl.matchId, cl.state
+	// spectators leaving are ignored
+	var leaves []string
+	for _, p := range msg.Leaves {
+		if userId := p.GetUserId(); userId != cl.userId && (state == nil || state.State.Seated(userId)) {
+			leaves = append(leaves, userId)
 		}
 	}
+	// the seat of a player leaving a game in progress is reserved for
+	// them to reconnect
+	if len(leaves) != 0 && (state == nil || state.State.Winner != 0 || state.State.Draw) {
+		cl.state = nil
+		cl.change()
+	}
+	cl.rw.Unlock()
+	for range leaves {
+		cl.emit(EventOpponentLeft, matchId, state, nil)
+	}
+	if len(leaves) != 0 && cl.stateHandler != nil {
+		cl.stateHandler(ctx)
+	}
 	if cl.matchPresenceEventHandler != nil {
 		cl.matchPresenceEventHandler(ctx, msg)
 	}
@@ -264,8 +447,20 @@ func (cl *Client) MatchPresenceEventHandler(ctx context.Context, msg *nakama.Mat
 func (cl *Client) MatchmakerMatchedHandler(ctx context.Context, msg *nakama.MatchmakerMatchedMsg) {
 	cl.logf("MatchmakerMatched: %+v", msg)
 	matchId := msg.GetMatchId()
+	// the matchmaker ticket is consumed once matched
+	cl.rw.Lock()
+	conn := cl.conn
+	cl.ticketId, cl.joining = "", false
+	cl.rw.Unlock()
+	if matchId == "" {
+		cl.logf("error: MatchmakerMatched: no match created")
+		if cl.matchmakerMatchedHandler != nil {
+			cl.matchmakerMatchedHandler(ctx, msg)
+		}
+		return
+	}
 	cl.logf("MatchmakerMatched: joining match %q", matchId)
-	cl.conn.MatchJoinAsync(ctx, matchId, nil, func(msg *nakama.MatchMsg, err error) {
+	conn.MatchJoinAsync(ctx, matchId, cl.metadata(false), func(msg *nakama.MatchMsg, err error) {
 		switch {
 		case err != nil:
 			cl.logf("error: MatchmakerMatched: unable to join match: %v", err)
@@ -274,6 +469,7 @@ func (cl *Client) MatchmakerMatchedHandler(ctx context.Context, msg *nakama.Matc
 			defer cl.rw.Unlock()
 			cl.matchId = msg.GetMatchId()
 			cl.logf("MatchmakerMatched: joined match %q", cl.matchId)
+			cl.emit(EventMatchFound, cl.matchId, nil, nil)
 		}
 	})
 	if cl.matchmakerMatchedHandler != nil {
@@ -309,18 +505,72 @@ func (cl *Client) StreamPresenceEventHandler(ctx context.Context, msg *nakama.St
 	}
 }
 
-func (cl *Client) Join(ctx context.Context) error {
+// Join adds the client to the matchmaker, using the options to restrict the
+// matched opponents.
+func (cl *Client) Join(ctx context.Context, opts ...JoinOption) error {
 	cl.logf("Join: joining match")
-	if cl.ticketId != "" {
-		return fmt.Errorf("waiting matchmaker %s", cl.ticketId)
+	o := &joinOptions{
+		stringProps:  make(map[string]string),
+		numericProps: make(map[string]float64),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.variant != nil {
+		if err := o.variant.Valid(); err != nil {
+			return err
+		}
+		o.stringProps[PropVariant] = o.variant.String()
+	}
+	if bestOf, ok := o.numericProps[PropBestOf]; ok {
+		if err := ValidBestOf(int(bestOf)); err != nil {
+			return err
+		}
+	}
+	minCount := 2
+	if cl.botLevel != "" {
+		// allow the matchmaker to match a single player, after waiting for
+		// an opponent for its max intervals, who will be joined by a bot
+		// opponent
+		minCount, o.stringProps[PropBot] = 1, string(cl.botLevel)
+	}
+	msg := nakama.MatchmakerAdd(o.buildQuery(), minCount, 2)
+	if len(o.stringProps) != 0 {
+		msg = msg.WithStringProperties(o.stringProps)
+	}
+	if len(o.numericProps) != 0 {
+		msg = msg.WithNumericProperties(o.numericProps)
+	}
+	// reserve the ticket, so that only one ticket is added at a time
+	cl.rw.Lock()
+	conn := cl.conn
+	switch {
+	case conn == nil:
+		cl.rw.Unlock()
+		return fmt.Errorf("not connected")
+	case cl.joining:
+		cl.rw.Unlock()
+		return fmt.Errorf("waiting matchmaker ticket")
+	case cl.ticketId != "":
+		ticketId := cl.ticketId
+		cl.rw.Unlock()
+		return fmt.Errorf("waiting matchmaker %s", ticketId)
 	}
-	cl.conn.MatchmakerAddAsync(ctx, nakama.MatchmakerAdd("*", 2, 2), func(msg *nakama.MatchmakerTicketMsg, err error) {
+	cl.joining = true
+	cl.rw.Unlock()
+	conn.MatchmakerAddAsync(ctx, msg, func(msg *nakama.MatchmakerTicketMsg, err error) {
+		cl.rw.Lock()
+		defer cl.rw.Unlock()
+		joining := cl.joining
+		cl.joining = false
 		switch {
 		case err != nil:
 			cl.logf("Join: unable to join match: %v", err)
+		case !joining:
+			// left, or matched, before the ticket was added
+			cl.logf("Join: removing matchmaker ticket %q", msg.GetTicket())
+			conn.MatchmakerRemoveAsync(ctx, msg.GetTicket(), nil)
 		default:
-			cl.rw.Lock()
-			defer cl.rw.Unlock()
 			ticketId := msg.GetTicket()
 			cl.logf("Join: added matchmaker ticket %q", ticketId)
 			cl.ticketId = ticketId
@@ -329,9 +579,9 @@ func (cl *Client) Join(ctx context.Context) error {
 	return nil
 }
 
-func (cl *Client) JoinAsync(ctx context.Context, f func(error)) {
+func (cl *Client) JoinAsync(ctx context.Context, f func(error), opts ...JoinOption) {
 	go func() {
-		if err := cl.Join(ctx); f != nil {
+		if err := cl.Join(ctx, opts...); f != nil {
 			f(err)
 		}
 	}()
@@ -347,7 +597,8 @@ func (cl *Client) Leave(ctx context.Context) error {
 	if cl.matchId != "" {
 		cl.conn.MatchLeaveAsync(ctx, cl.matchId, nil)
 	}
-	cl.ticketId, cl.matchId, cl.waiting, cl.state = "", "", true, nil
+	cl.ticketId, cl.joining, cl.matchId, cl.spectator, cl.waiting, cl.state, cl.merr = "", false, "", false, true, nil, nil
+	cl.change()
 	return nil
 }
 
@@ -362,19 +613,97 @@ func (cl *Client) LeaveAsync(ctx context.Context, f func(error)) {
 func (cl *Client) Move(ctx context.Context, row, col int) error {
 	cl.logf("Move: moving %d, %d", row, col)
 	cl.rw.RLock()
-	matchId, state := cl.matchId, cl.state
+	conn, matchId, spectator, state := cl.conn, cl.matchId, cl.spectator, cl.state
 	cl.rw.RUnlock()
-	if matchId == "" || state == nil {
+	switch {
+	case matchId == "" || state == nil:
 		return fmt.Errorf("no active match")
+	case spectator:
+		return fmt.Errorf("cannot move while spectating")
 	}
-	data, err := NewMove(row, col).Marshal()
+	move := NewMove(row, col)
+	move.Seq = state.Seq
+	data, err := cl.codec.Marshal(move)
 	if err != nil {
 		return fmt.Errorf("unable to marshal move: %w", err)
 	}
 	cl.rw.Lock()
-	defer cl.rw.Unlock()
-	cl.waiting = true
-	return cl.conn.MatchDataSend(ctx, matchId, OpCodeMove, data, true, nil)
+	cl.waiting, cl.merr = true, nil
+	cl.change()
+	cl.rw.Unlock()
+	if err := conn.MatchDataSend(ctx, matchId, OpCodeMove, data, true, nil); err != nil {
+		cl.rw.Lock()
+		defer cl.rw.Unlock()
+		cl.waiting = false
+		cl.change()
+		return err
+	}
+	return nil
+}
+
+// RequestTakeback requests the other player's agreement to take back the
+// client's last move. Takebacks are only permitted in casual games.
+func (cl *Client) RequestTakeback(ctx context.Context) error {
+	cl.logf("RequestTakeback: requesting takeback")
+	return cl.send(ctx, OpCodeTakebackRequest, nil)
+}
+
+// RespondTakeback accepts or declines the other player's takeback request.
+func (cl *Client) RespondTakeback(ctx context.Context, accept bool) error {
+	cl.logf("RespondTakeback: accept %t", accept)
+	data, err := cl.codec.Marshal(Response{Accept: accept})
+	if err != nil {
+		return fmt.Errorf("unable to marshal takeback response: %w", err)
+	}
+	return cl.send(ctx, OpCodeTakebackResponse, data)
+}
+
+// Resign resigns the current game.
+func (cl *Client) Resign(ctx context.Context) error {
+	cl.logf("Resign: resigning")
+	return cl.send(ctx, OpCodeResign, nil)
+}
+
+// OfferDraw offers the other player a draw.
+func (cl *Client) OfferDraw(ctx context.Context) error {
+	cl.logf("OfferDraw: offering draw")
+	return cl.send(ctx, OpCodeDrawOffer, nil)
+}
+
+// RespondDraw accepts or declines the other player's draw offer.
+func (cl *Client) RespondDraw(ctx context.Context, accept bool) error {
+	cl.logf("RespondDraw: accept %t", accept)
+	data, err := cl.codec.Marshal(Response{Accept: accept})
+	if err != nil {
+		return fmt.Errorf("unable to marshal draw response: %w", err)
+	}
+	return cl.send(ctx, OpCodeDrawResponse, data)
+}
+
+// Rematch votes for or against a rematch after a game ends. The next game
+// starts when both players vote for a rematch, and the match ends when either
+// votes against.
+func (cl *Client) Rematch(ctx context.Context, accept bool) error {
+	cl.logf("Rematch: accept %t", accept)
+	data, err := cl.codec.Marshal(Response{Accept: accept})
+	if err != nil {
+		return fmt.Errorf("unable to marshal rematch vote: %w", err)
+	}
+	return cl.send(ctx, OpCodeRematch, data)
+}
+
+// send sends the match data to the active match.
+func (cl *Client) send(ctx context.Context, opCode int64, data []byte) error {
+	cl.rw.RLock()
+	conn, matchId, spectator, state := cl.conn, cl.matchId, cl.spectator, cl.state
+	cl.rw.RUnlock()
+	switch {
+	case matchId == "" || state == nil:
+		return fmt.Errorf("no active match")
+	case spectator:
+		return fmt.Errorf("cannot send while spectating")
+	}
+	return conn.MatchDataSend(ctx, matchId, opCode, data, true)
 }
 
 func (cl *Client) MoveAsync(ctx context.Context, row, col int, f func(error)) {
@@ -385,6 +714,143 @@ func (cl *Client) MoveAsync(ctx context.Context, row, col int, f func(error)) {
 	}()
 }
 
+// Ratings retrieves the ratings for the users, or for the client's user when
+// no user ids are provided.
+func (cl *Client) Ratings(ctx context.Context, userIds ...string) ([]PlayerRating, error) {
+	res := new(RatingsResponse)
+	if err := cl.cl.Rpc(ctx, RpcRatings, RatingsRequest{UserIds: userIds}, res); err != nil {
+		return nil, fmt.Errorf("unable to retrieve ratings: %w", err)
+	}
+	return res.Ratings, nil
+}
+
+// History lists the client's match records, without the games' moves.
+// Returns the cursor for the next page, if any.
+func (cl *Client) History(ctx context.Context, limit int, cursor string) ([]MatchRecord, string, error) {
+	res := new(HistoryResponse)
+	if err := cl.cl.Rpc(ctx, RpcHistory, HistoryRequest{Limit: limit, Cursor: cursor}, res); err != nil {
+		return nil, "", fmt.Errorf("unable to retrieve history: %w", err)
+	}
+	return res.Matches, res.Cursor, nil
+}
+
+// Replay retrieves the client's match record for the match, including the
+// games' moves.
+func (cl *Client) Replay(ctx context.Context, matchId string) (*MatchRecord, error) {
+	res := new(ReplayResponse)
+	if err := cl.cl.Rpc(ctx, RpcReplay, ReplayRequest{MatchId: matchId}, res); err != nil {
+		return nil, fmt.Errorf("unable to retrieve replay: %w", err)
+	}
+	return &res.Match, nil
+}
+
+// CreatePrivate creates and joins a private match for the variant and best of
+// n series (0 for a series without a limit), returning the join code to share
+// with the other player. The match is casual, and does not affect the
+// players' ratings, unless rated is set.
+func (cl *Client) CreatePrivate(ctx context.Context, variant Variant, bestOf int, rated bool) (string, error) {
+	if err := variant.Valid(); err != nil {
+		return "", err
+	}
+	if err := ValidBestOf(bestOf); err != nil {
+		return "", err
+	}
+	req := CreatePrivateRequest{
+		Variant: variant.String(),
+		BestOf:  bestOf,
+		Rated:   rated,
+	}
+	res := new(CreatePrivateResponse)
+	if err := cl.cl.Rpc(ctx, RpcCreatePrivate, req, res); err != nil {
+		return "", fmt.Errorf("unable to create private match: %w", err)
+	}
+	if err := cl.joinMatch(ctx, res.MatchId, false); err != nil {
+		return "", err
+	}
+	return res.Code, nil
+}
+
+func (cl *Client) CreatePrivateAsync(ctx context.Context, variant Variant, bestOf int, rated bool, f func(string, error)) {
+	go func() {
+		if code, err := cl.CreatePrivate(ctx, variant, bestOf, rated); f != nil {
+			f(code, err)
+		}
+	}()
+}
+
+// JoinByCode joins the private match with the join code.
+func (cl *Client) JoinByCode(ctx context.Context, code string) error {
+	res := new(JoinCodeResponse)
+	if err := cl.cl.Rpc(ctx, RpcJoinCode, JoinCodeRequest{Code: code}, res); err != nil {
+		return fmt.Errorf("unable to join code %q: %w", code, err)
+	}
+	return cl.joinMatch(ctx, res.MatchId, false)
+}
+
+func (cl *Client) JoinByCodeAsync(ctx context.Context, code string, f func(error)) {
+	go func() {
+		if err := cl.JoinByCode(ctx, code); f != nil {
+			f(err)
+		}
+	}()
+}
+
+// Spectate joins the match as a spectator. Spectators receive the match state
+// through the state handler and the event stream, and cannot move.
+func (cl *Client) Spectate(ctx context.Context, matchId string) error {
+	return cl.joinMatch(ctx, matchId, true)
+}
+
+func (cl *Client) SpectateAsync(ctx context.Context, matchId string, f func(error)) {
+	go func() {
+		if err := cl.Spectate(ctx, matchId); f != nil {
+			f(err)
+		}
+	}()
+}
+
+// joinMatch joins the match.
+func (cl *Client) joinMatch(ctx context.Context, matchId string, spectator bool) error {
+	cl.rw.RLock()
+	conn, ticketId, joining, currentId := cl.conn, cl.ticketId, cl.joining, cl.matchId
+	cl.rw.RUnlock()
+	switch {
+	case conn == nil:
+		return fmt.Errorf("not connected")
+	case joining:
+		return fmt.Errorf("waiting matchmaker ticket")
+	case ticketId != "":
+		return fmt.Errorf("waiting matchmaker %s", ticketId)
+	case currentId != "":
+		return fmt.Errorf("already in match %s", currentId)
+	}
+	cl.logf("joinMatch: joining match %q", matchId)
+	msg, err := conn.MatchJoin(ctx, matchId, cl.metadata(spectator))
+	if err != nil {
+		return fmt.Errorf("unable to join match %s: %w", matchId, err)
+	}
+	cl.rw.Lock()
+	defer cl.rw.Unlock()
+	cl.matchId, cl.spectator = msg.GetMatchId(), spectator
+	cl.logf("joinMatch: joined match %q", cl.matchId)
+	cl.emit(EventMatchFound, cl.matchId, nil, nil)
+	return nil
+}
+
+// metadata returns the match join metadata.
+func (cl *Client) metadata(spectator bool) map[string]string {
+	metadata := map[string]string{
+		MetaProtocol: strconv.Itoa(ProtocolVersion),
+	}
+	if spectator {
+		metadata[MetaSpectator] = "true"
+	}
+	if cl.codec != JSONCodec {
+		metadata[MetaCodec] = cl.codec.Name()
+	}
+	return metadata
+}
+
 type Option func(*Client)
 
 func WithServerKey(serverKey string) Option {
@@ -429,6 +895,23 @@ func WithPersist() Option {
 	}
 }
 
+// WithBot is a client option to play against a bot opponent of the level,
+// when no other player is matched within the matchmaker's max intervals
+// (matchmaker.max_intervals times matchmaker.interval_sec in the Nakama
+// configuration).
+func WithBot(level BotLevel) Option {
+	return func(cl *Client) {
+		cl.botLevel = level
+	}
+}
+
+// WithCodec is a client option to set the codec used for match messages.
+func WithCodec(codec Codec) Option {
+	return func(cl *Client) {
+		cl.codec = codec
+	}
+}
+
 func WithHandler(handler nakama.ConnHandler) Option {
 	return func(cl *Client) {
 		if x, ok := handler.(interface {
@@ -498,3 +981,84 @@ func WithHandler(handler nakama.ConnHandler) Option {
 		}
 	}
 }
+
+// JoinOption is a matchmaker option for Join.
+type JoinOption func(*joinOptions)
+
+// joinOptions are the matchmaker options.
+type joinOptions struct {
+	query        string
+	variant      *Variant
+	stringProps  map[string]string
+	numericProps map[string]float64
+	spread       float64
+}
+
+// buildQuery builds the matchmaker query matching the options.
+func (o *joinOptions) buildQuery() string {
+	var terms []string
+	if o.query != "" {
+		terms = append(terms, o.query)
+	}
+	if region, ok := o.stringProps[PropRegion]; ok {
+		terms = append(terms, fmt.Sprintf("+properties.%s:%s", PropRegion, region))
+	}
+	if variant, ok := o.stringProps[PropVariant]; ok {
+		terms = append(terms, fmt.Sprintf("+properties.%s:%s", PropVariant, variant))
+	}
+	if bestOf, ok := o.numericProps[PropBestOf]; ok {
+		terms = append(terms, fmt.Sprintf("+properties.%s:%d", PropBestOf, int(bestOf)))
+	}
+	if r, ok := o.numericProps[PropRating]; ok && o.spread > 0 {
+		terms = append(
+			terms,
+			fmt.Sprintf("+properties.%s:>=%d", PropRating, int(math.Floor(r-o.spread))),
+			fmt.Sprintf("+properties.%s:<=%d", PropRating, int(math.Ceil(r+o.spread))),
+		)
+	}
+	if len(terms) == 0 {
+		return "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// WithJoinQuery is a join option to add a matchmaker query, in addition to
+// the terms added by the other options.
+func WithJoinQuery(query string) JoinOption {
+	return func(o *joinOptions) {
+		o.query = query
+	}
+}
+
+// WithJoinRating is a join option to match opponents with a rating within
+// spread of rating. A spread of 0 matches opponents of any rating.
+func WithJoinRating(rating, spread float64) JoinOption {
+	return func(o *joinOptions) {
+		o.numericProps[PropRating], o.spread = rating, spread
+		if spread > 0 {
+			o.numericProps[PropRatingSpread] = spread
+		}
+	}
+}
+
+// WithJoinRegion is a join option to match opponents in the same region.
+func WithJoinRegion(region string) JoinOption {
+	return func(o *joinOptions) {
+		o.stringProps[PropRegion] = region
+	}
+}
+
+// WithJoinVariant is a join option to match opponents playing the variant.
+func WithJoinVariant(variant Variant) JoinOption {
+	return func(o *joinOptions) {
+		o.variant = &variant
+	}
+}
+
+// WithJoinBestOf is a join option to match opponents playing a best of n
+// series.
+func WithJoinBestOf(n int) JoinOption {
+	return func(o *joinOptions) {
+		o.numericProps[PropBestOf] = float64(n)
+	}
+}
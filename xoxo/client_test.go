@@ -0,0 +1,187 @@
+package xoxo_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ascii8/nakama-go"
+	"github.com/ascii8/xoxo-go/xoxo"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestEvents(t *testing.T) {
+	cl := xoxo.NewClient(xoxo.WithUserId("0"))
+	defer cl.Close()
+	events := cl.Events()
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	ctx := context.Background()
+	send := func(yourTurn bool) {
+		data, err := (&xoxo.MatchState{
+			State:    state,
+			YourTurn: yourTurn,
+		}).Marshal()
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		cl.MatchDataHandler(ctx, &nakama.MatchDataMsg{
+			OpCode: xoxo.OpCodeState,
+			Data:   data,
+		})
+	}
+	send(true)
+	for i, move := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}} {
+		if err := state.Move(strconv.Itoa(i%2), xoxo.NewMove(move[0], move[1])); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if state.Winner != 0 {
+			state.RematchCountdown = 2
+		}
+		send(state.PlayerTurn == 1)
+	}
+	state.RematchCountdown = 1
+	send(false)
+	cl.MatchPresenceEventHandler(ctx, &nakama.MatchPresenceEventMsg{
+		Leaves: []*nakama.UserPresenceMsg{{UserId: "1"}},
+	})
+	exp := []xoxo.EventType{
+		xoxo.EventStateChanged, xoxo.EventYourTurn,
+		xoxo.EventStateChanged,
+		xoxo.EventStateChanged, xoxo.EventYourTurn,
+		xoxo.EventStateChanged,
+		xoxo.EventStateChanged, xoxo.EventYourTurn,
+		xoxo.EventStateChanged, xoxo.EventGameOver, xoxo.EventRematchCountdown,
+		xoxo.EventStateChanged, xoxo.EventRematchCountdown,
+		xoxo.EventOpponentLeft,
+	}
+	for i, typ := range exp {
+		select {
+		case ev := <-events:
+			if ev.Type != typ {
+				t.Errorf("expected event %d to be %s, got: %s", i, typ, ev.Type)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatalf("expected event %d %s", i, typ)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if cl.Next(ctx) {
+		t.Errorf("expected Next to return false after leave")
+	}
+}
+
+func TestClientRace(t *testing.T) {
+	h := &stateHandler{}
+	cl := xoxo.NewClient(xoxo.WithUserId("0"), xoxo.WithHandler(h))
+	h.cl = cl
+	defer cl.Close()
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var eg errgroup.Group
+	for i := 0; i < 4; i++ {
+		eg.Go(func() error {
+			var last int64
+			for ctx.Err() == nil {
+				if state := cl.State(); state != nil {
+					// the seq only increases, and the game is exactly one of
+					// won, drawn or in progress
+					if state.Seq < last {
+						return fmt.Errorf("expected seq >= %d, got: %d", last, state.Seq)
+					}
+					last = state.Seq
+					n := 0
+					for _, b := range []bool{
+						state.State.Winner != 0,
+						state.State.Draw,
+						state.State.PlayerTurn == 1 || state.State.PlayerTurn == 2,
+					} {
+						if b {
+							n++
+						}
+					}
+					if n != 1 {
+						return fmt.Errorf("expected one of winner, draw or in progress, got: %s", state.State)
+					}
+				}
+				_, _, _, _ = cl.MatchId(), cl.Connected(), cl.Spectating(), cl.Err()
+				time.Sleep(100 * time.Microsecond)
+			}
+			return nil
+		})
+	}
+	eg.Go(func() error {
+		for ctx.Err() == nil && cl.Ready(ctx) {
+			cl.Next(ctx)
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	})
+	moves := [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}}
+	var seq int64
+	for games := 0; games < 20; games++ {
+		state := state.Copy()
+		for i := 0; i <= len(moves); i++ {
+			if i != 0 {
+				move := moves[i-1]
+				if err := state.Move(strconv.Itoa((i-1)%2), xoxo.NewMove(move[0], move[1])); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+			}
+			seq++
+			data, err := (&xoxo.MatchState{
+				State:    state.Copy(),
+				YourTurn: state.PlayerTurn == 1,
+				Seq:      seq,
+			}).Marshal()
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			cl.MatchDataHandler(ctx, &nakama.MatchDataMsg{
+				OpCode: xoxo.OpCodeState,
+				Data:   data,
+			})
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+	cancel()
+	if err := eg.Wait(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if h.calls == 0 {
+		t.Errorf("expected state handler calls")
+	}
+	if state := cl.State(); state == nil || state.Seq != seq || state.State.Winner != 1 {
+		t.Errorf("expected final state, got: %v", state)
+	}
+}
+
+func TestClientNotConnected(t *testing.T) {
+	cl := xoxo.NewClient()
+	defer cl.Close()
+	ctx := context.Background()
+	if err := cl.Join(ctx); err == nil {
+		t.Errorf("expected error joining without a connection")
+	}
+	if err := cl.Spectate(ctx, "match"); err == nil {
+		t.Errorf("expected error spectating without a connection")
+	}
+	if cl.Connected() {
+		t.Errorf("expected client not to be connected")
+	}
+}
+
+// stateHandler is a state handler reading the client's state.
+type stateHandler struct {
+	cl    *xoxo.Client
+	calls int
+}
+
+func (h *stateHandler) StateHandler(ctx context.Context) {
+	// the client's lock is not held while handlers are called
+	_, _ = h.cl.State(), h.cl.MatchId()
+	h.calls++
+}
@@ -0,0 +1,65 @@
+package xoxo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MetaCodec is the match join metadata key selecting the codec used for the
+// client's match messages.
+const MetaCodec = "codec"
+
+// Codec encodes and decodes match messages.
+type Codec interface {
+	// Name is the codec's name, sent in the match join metadata.
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(buf []byte, v interface{}) error
+}
+
+// Codecs.
+var (
+	// JSONCodec encodes match messages as JSON. Used when no codec is
+	// requested, for compatibility with existing clients.
+	JSONCodec Codec = jsonCodec{}
+	// BinaryCodec encodes match messages in a compact, versioned binary
+	// format.
+	BinaryCodec Codec = binaryCodec{}
+)
+
+// ParseCodec returns the codec with the name, or the JSON codec when name is
+// empty.
+func ParseCodec(name string) (Codec, error) {
+	switch name {
+	case "", JSONCodec.Name():
+		return JSONCodec, nil
+	case BinaryCodec.Name():
+		return BinaryCodec, nil
+	}
+	return nil, fmt.Errorf("invalid codec %q", name)
+}
+
+// jsonCodec is the JSON codec.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(interface {
+		Marshal() ([]byte, error)
+	}); ok {
+		return m.Marshal()
+	}
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(buf []byte, v interface{}) error {
+	if m, ok := v.(interface {
+		Unmarshal([]byte) error
+	}); ok {
+		return m.Unmarshal(buf)
+	}
+	return json.Unmarshal(buf, v)
+}
@@ -0,0 +1,150 @@
+package xoxo_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestCodec(t *testing.T) {
+	state := newTwoPlayerState(t, xoxo.Variant{Rows: 4, Cols: 5, K: 4})
+	state.SetTimeControl(xoxo.TimeControl{Game: 60, Turn: 10, Increment: 2})
+	state.Series, state.Rematch = xoxo.NewSeries(3), []bool{true, false}
+	prev := &xoxo.MatchState{State: state.Copy(), Seq: 1}
+	if err := state.Move("0", xoxo.NewMove(2, 3)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	next := &xoxo.MatchState{
+		ActivePlayer: &state.Players[1],
+		OtherPlayer:  &state.Players[0],
+		State:        state,
+		Seq:          2,
+	}
+	d, _ := xoxo.Diff(prev, next)
+	move := xoxo.NewMove(1, 1)
+	tests := []interface{}{
+		next,
+		d,
+		&move,
+		&xoxo.Response{Accept: true},
+		xoxo.NewMatchError(xoxo.OpCodeMove, xoxo.ErrNotYourTurn, &move),
+	}
+	for _, codec := range []xoxo.Codec{xoxo.JSONCodec, xoxo.BinaryCodec} {
+		c, err := xoxo.ParseCodec(codec.Name())
+		if err != nil || c != codec {
+			t.Fatalf("expected codec %s, got: %v %v", codec.Name(), c, err)
+		}
+		for i, test := range tests {
+			buf, err := codec.Marshal(test)
+			if err != nil {
+				t.Fatalf("%s test %d expected no error, got: %v", codec.Name(), i, err)
+			}
+			v := reflect.New(reflect.TypeOf(test).Elem()).Interface()
+			if err := codec.Unmarshal(buf, v); err != nil {
+				t.Fatalf("%s test %d expected no error, got: %v", codec.Name(), i, err)
+			}
+			exp, _ := xoxo.JSONCodec.Marshal(test)
+			got, _ := xoxo.JSONCodec.Marshal(v)
+			if string(exp) != string(got) {
+				t.Errorf("%s test %d expected:\n%s\ngot:\n%s", codec.Name(), i, exp, got)
+			}
+		}
+	}
+	// unknown fields are ignored by the binary codec
+	buf, err := xoxo.BinaryCodec.Marshal(&move)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var m xoxo.Move
+	if err := xoxo.BinaryCodec.Unmarshal(append(buf, 0x78, 0x01), &m); err != nil || m != move {
+		t.Errorf("expected %v, got: %v %v", move, m, err)
+	}
+	if _, err := xoxo.ParseCodec("xml"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestCodecMalformed(t *testing.T) {
+	// varint and ints fields, as encoded by the binary codec
+	varint := func(b []byte, num protowire.Number, v int64) []byte {
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+	}
+	ints := func(b []byte, num protowire.Number, v ...int64) []byte {
+		var p []byte
+		for _, i := range v {
+			p = protowire.AppendVarint(p, protowire.EncodeZigZag(i))
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, p)
+	}
+	message := func(b []byte, num protowire.Number, m []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, m)
+	}
+	board := func(rows, cols int64, cells ...int64) []byte {
+		return ints(varint(varint(nil, 1, rows), 2, cols), 4, cells...)
+	}
+	buf, err := xoxo.BinaryCodec.Marshal(&xoxo.MatchState{State: xoxo.NewState(), Seq: 1})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+		v    interface{}
+	}{
+		{"truncated", buf[:len(buf)-1], new(xoxo.MatchState)},
+		{"negative rows", message(nil, 1, board(-1, -3, 0, 0, 0)), new(xoxo.MatchState)},
+		{"oversized board", message(nil, 1, board(xoxo.MaxBoardSize+1, 1)), new(xoxo.MatchState)},
+		{"cell count", message(nil, 1, board(3, 3, 0, 0)), new(xoxo.MatchState)},
+		{"missing cells", message(nil, 1, varint(varint(nil, 1, 3), 2, 3)), new(xoxo.MatchState)},
+		{"no rows", message(nil, 1, board(0, 3, 0, 0, 0)), new(xoxo.MatchState)},
+		{"players", message(nil, 1, message(message(message(nil, 6, nil), 6, nil), 6, nil)), new(xoxo.MatchState)},
+		{"player turn", message(nil, 1, varint(nil, 5, 3)), new(xoxo.MatchState)},
+		{"clocks", message(nil, 1, ints(nil, 12, 60)), new(xoxo.MatchState)},
+		{"series wins", message(nil, 1, message(nil, 19, ints(varint(nil, 1, 3), 3, 1))), new(xoxo.MatchState)},
+		{"delta undo", varint(nil, 4, -1), new(xoxo.Delta)},
+		{"delta series wins", message(nil, 17, varint(nil, 1, 3)), new(xoxo.Delta)},
+		{"delta rematch", message(nil, 16, []byte{1, 0, 1}), new(xoxo.Delta)},
+	}
+	for _, test := range tests {
+		if err := xoxo.BinaryCodec.Unmarshal(test.buf, test.v); err == nil {
+			t.Errorf("%s expected error", test.name)
+		}
+	}
+}
+
+func FuzzBinaryCodec(f *testing.F) {
+	state := newTwoPlayerState(f, xoxo.Variant{Rows: 4, Cols: 5, K: 4})
+	state.SetTimeControl(xoxo.TimeControl{Game: 60, Turn: 10})
+	state.Series = xoxo.NewSeries(3)
+	prev := &xoxo.MatchState{State: state.Copy(), Seq: 1}
+	if err := state.Move("0", xoxo.NewMove(2, 3)); err != nil {
+		f.Fatalf("expected no error, got: %v", err)
+	}
+	next := &xoxo.MatchState{State: state, Seq: 2}
+	d, _ := xoxo.Diff(prev, next)
+	for _, v := range []interface{}{prev, next, d} {
+		buf, err := xoxo.BinaryCodec.Marshal(v)
+		if err != nil {
+			f.Fatalf("expected no error, got: %v", err)
+		}
+		f.Add(buf)
+	}
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		var m xoxo.MatchState
+		if err := xoxo.BinaryCodec.Unmarshal(buf, &m); err == nil && m.State != nil {
+			_ = m.State.String()
+			if m.State.Series != nil {
+				_ = m.State.Series.String()
+			}
+		}
+		var d xoxo.Delta
+		if err := xoxo.BinaryCodec.Unmarshal(buf, &d); err == nil {
+			_, _ = d.Apply(prev)
+		}
+	})
+}
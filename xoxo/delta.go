@@ -0,0 +1,138 @@
+package xoxo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Cell is a changed cell.
+type Cell struct {
+	Row    int `json:"row"`
+	Col    int `json:"col"`
+	Player int `json:"player"`
+}
+
+// Delta is the change between two match states sent by the match. Changes to
+// the variant, time control or players are only sent as a full match state.
+type Delta struct {
+	// Seq is the sequence number of the match state after the delta.
+	Seq int64 `json:"seq"`
+	// Base is the sequence number of the match state the delta applies to.
+	Base  int64  `json:"base"`
+	Cells []Cell `json:"cells,omitempty"`
+	// Undo is the number of moves taken back, before appending Moves.
+	Undo             int     `json:"undo,omitempty"`
+	Moves            []Move  `json:"moves,omitempty"`
+	PlayerTurn       int     `json:"player_turn"`
+	YourTurn         bool    `json:"your_turn,omitempty"`
+	Winner           Winner  `json:"winner,omitempty"`
+	Draw             bool    `json:"draw,omitempty"`
+	Reason           Reason  `json:"reason,omitempty"`
+	RematchCountdown int     `json:"rematch_countdown,omitempty"`
+	Clocks           []int   `json:"clocks,omitempty"`
+	TurnClock        int     `json:"turn_clock,omitempty"`
+	Takeback         int     `json:"takeback,omitempty"`
+	DrawOffer        int     `json:"draw_offer,omitempty"`
+	Rematch          []bool  `json:"rematch,omitempty"`
+	Series           *Series `json:"series,omitempty"`
+	Finished         bool    `json:"finished,omitempty"`
+}
+
+// Diff returns the delta from prev to next. Returns false when next can only
+// be sent as a full match state.
+func Diff(prev, next *MatchState) (*Delta, bool) {
+	switch {
+	case prev == nil || next == nil || prev.State == nil || next.State == nil,
+		prev.Spectator != next.Spectator,
+		prev.State.Variant != next.State.Variant,
+		prev.State.Casual != next.State.Casual,
+		!reflect.DeepEqual(prev.State.TimeControl, next.State.TimeControl),
+		!reflect.DeepEqual(prev.State.Players, next.State.Players):
+		return nil, false
+	}
+	s := next.State
+	d := &Delta{
+		Seq:              next.Seq,
+		Base:             prev.Seq,
+		PlayerTurn:       s.PlayerTurn,
+		YourTurn:         next.YourTurn,
+		Winner:           s.Winner,
+		Draw:             s.Draw,
+		Reason:           s.Reason,
+		RematchCountdown: s.RematchCountdown,
+		Clocks:           s.Clocks,
+		TurnClock:        s.TurnClock,
+		Takeback:         s.Takeback,
+		DrawOffer:        s.DrawOffer,
+		Rematch:          s.Rematch,
+		Series:           s.Series,
+		Finished:         s.Finished,
+	}
+	for i := 0; i < s.Rows; i++ {
+		for j := 0; j < s.Cols; j++ {
+			if p := s.Cells[i][j]; p != prev.State.Cells[i][j] {
+				d.Cells = append(d.Cells, Cell{Row: i, Col: j, Player: p})
+			}
+		}
+	}
+	n := 0
+	for n < len(prev.State.Moves) && n < len(s.Moves) && prev.State.Moves[n] == s.Moves[n] {
+		n++
+	}
+	d.Undo, d.Moves = len(prev.State.Moves)-n, s.Moves[n:]
+	return d, true
+}
+
+// Apply applies the delta to the match state, returning the new match state.
+// Returns an error when the delta does not apply to the match state.
+func (d *Delta) Apply(m *MatchState) (*MatchState, error) {
+	switch {
+	case m == nil || m.State == nil:
+		return nil, fmt.Errorf("no state to apply delta %d", d.Seq)
+	case m.Seq != d.Base:
+		return nil, fmt.Errorf("delta %d applies to state %d, not %d", d.Seq, d.Base, m.Seq)
+	case d.Undo < 0 || len(m.State.Moves) < d.Undo:
+		return nil, fmt.Errorf("delta %d takes back %d of %d moves", d.Seq, d.Undo, len(m.State.Moves))
+	}
+	s := m.State.Copy()
+	for _, c := range d.Cells {
+		if c.Row < 0 || s.Rows <= c.Row || c.Col < 0 || s.Cols <= c.Col {
+			return nil, fmt.Errorf("delta %d has invalid cell %d, %d", d.Seq, c.Row, c.Col)
+		}
+		s.Cells[c.Row][c.Col] = c.Player
+	}
+	s.Moves = append(s.Moves[:len(s.Moves)-d.Undo], d.Moves...)
+	s.PlayerTurn, s.Winner, s.Draw, s.Reason = d.PlayerTurn, d.Winner, d.Draw, d.Reason
+	s.RematchCountdown, s.Clocks, s.TurnClock = d.RematchCountdown, d.Clocks, d.TurnClock
+	s.Takeback, s.DrawOffer, s.Rematch = d.Takeback, d.DrawOffer, d.Rematch
+	s.Series, s.Finished = d.Series, d.Finished
+	next := &MatchState{
+		State:     s,
+		YourTurn:  d.YourTurn,
+		Spectator: m.Spectator,
+		Seq:       d.Seq,
+	}
+	if len(s.Players) == 2 {
+		next.ActivePlayer, next.OtherPlayer = &s.Players[0], &s.Players[1]
+		if s.PlayerTurn == 2 {
+			next.ActivePlayer, next.OtherPlayer = next.OtherPlayer, next.ActivePlayer
+		}
+	}
+	return next, nil
+}
+
+func (d *Delta) Marshal() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *Delta) Unmarshal(buf []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	return dec.Decode(d)
+}
@@ -0,0 +1,67 @@
+package xoxo_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestDelta(t *testing.T) {
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	view := func(seq int64) *xoxo.MatchState {
+		s := state.Copy()
+		active, other := &s.Players[0], &s.Players[1]
+		if s.PlayerTurn == 2 {
+			active, other = other, active
+		}
+		return &xoxo.MatchState{
+			ActivePlayer: active,
+			OtherPlayer:  other,
+			State:        s,
+			YourTurn:     s.PlayerTurn == 1,
+			Seq:          seq,
+		}
+	}
+	prev := view(1)
+	for i, m := range [][]int{{0, 0}, {1, 1}, {0, 1}} {
+		if err := state.Move(strconv.Itoa(i%2), xoxo.NewMove(m[0], m[1])); err != nil {
+			t.Fatalf("move %d expected no error, got: %v", i, err)
+		}
+		if i == 2 {
+			if err := state.Undo(); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+		next := view(int64(i + 2))
+		d, ok := xoxo.Diff(prev, next)
+		if !ok {
+			t.Fatalf("move %d expected delta", i)
+		}
+		buf, err := d.Marshal()
+		if err != nil {
+			t.Fatalf("move %d expected no error, got: %v", i, err)
+		}
+		d = new(xoxo.Delta)
+		if err := d.Unmarshal(buf); err != nil {
+			t.Fatalf("move %d expected no error, got: %v", i, err)
+		}
+		if _, err := d.Apply(next); err == nil {
+			t.Errorf("move %d expected error applying delta to the wrong state", i)
+		}
+		applied, err := d.Apply(prev)
+		if err != nil {
+			t.Fatalf("move %d expected no error, got: %v", i, err)
+		}
+		exp, _ := next.Marshal()
+		got, _ := applied.Marshal()
+		if string(exp) != string(got) {
+			t.Errorf("move %d expected:\n%s\ngot:\n%s", i, exp, got)
+		}
+		prev = applied
+	}
+	state.Players[1].Disconnected = true
+	if _, ok := xoxo.Diff(prev, view(prev.Seq+1)); ok {
+		t.Errorf("expected full state when players change")
+	}
+}
@@ -0,0 +1,110 @@
+package xoxo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Errors.
+var (
+	ErrInvalidMessage = errors.New("invalid message")
+	ErrInvalidMove    = errors.New("invalid move")
+	ErrNotYourTurn    = errors.New("not your turn")
+	ErrGameOver       = errors.New("game over")
+	ErrNotSeated      = errors.New("not seated")
+	ErrNotAllowed     = errors.New("not allowed")
+	ErrStaleMove      = errors.New("stale move")
+)
+
+// ErrorCode is a match error code.
+type ErrorCode int
+
+// Error codes.
+const (
+	ErrorUnknown ErrorCode = iota
+	ErrorInvalidMessage
+	ErrorInvalidMove
+	ErrorNotYourTurn
+	ErrorGameOver
+	ErrorNotSeated
+	ErrorNotAllowed
+	ErrorStaleMove
+)
+
+// errorCodes are the errors for each error code.
+var errorCodes = map[ErrorCode]error{
+	ErrorInvalidMessage: ErrInvalidMessage,
+	ErrorInvalidMove:    ErrInvalidMove,
+	ErrorNotYourTurn:    ErrNotYourTurn,
+	ErrorGameOver:       ErrGameOver,
+	ErrorNotSeated:      ErrNotSeated,
+	ErrorNotAllowed:     ErrNotAllowed,
+	ErrorStaleMove:      ErrStaleMove,
+}
+
+// CodeOf returns the error code for the error.
+func CodeOf(err error) ErrorCode {
+	for code, e := range errorCodes {
+		if errors.Is(err, e) {
+			return code
+		}
+	}
+	return ErrorUnknown
+}
+
+// String satisfies the fmt.Stringer interface.
+func (code ErrorCode) String() string {
+	if err, ok := errorCodes[code]; ok {
+		return err.Error()
+	}
+	return "unknown error"
+}
+
+// MatchError is the error sent to a player whose message was rejected.
+type MatchError struct {
+	// OpCode is the op code of the rejected message.
+	OpCode  int64     `json:"op_code"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Move is the rejected move.
+	Move *Move `json:"move,omitempty"`
+}
+
+// NewMatchError creates a match error for the rejected message.
+func NewMatchError(opCode int64, err error, move *Move) *MatchError {
+	return &MatchError{
+		OpCode:  opCode,
+		Code:    CodeOf(err),
+		Message: err.Error(),
+		Move:    move,
+	}
+}
+
+// Error satisfies the error interface.
+func (err *MatchError) Error() string {
+	if err.Move != nil {
+		return fmt.Sprintf("move %d, %d rejected: %s", err.Move.Row, err.Move.Col, err.Message)
+	}
+	return fmt.Sprintf("op code %d rejected: %s", err.OpCode, err.Message)
+}
+
+// Unwrap returns the error for the error code.
+func (err *MatchError) Unwrap() error {
+	return errorCodes[err.Code]
+}
+
+func (err *MatchError) Marshal() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(err); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (err *MatchError) Unmarshal(buf []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	return dec.Decode(err)
+}
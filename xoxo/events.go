@@ -0,0 +1,162 @@
+package xoxo
+
+// EventType is a client event type.
+type EventType int
+
+// Event types.
+const (
+	// EventMatchFound is sent when the client joins a match.
+	EventMatchFound EventType = iota + 1
+	// EventStateChanged is sent for every match state received.
+	EventStateChanged
+	// EventYourTurn is sent when it becomes the client's turn.
+	EventYourTurn
+	// EventGameOver is sent when a game is won or drawn.
+	EventGameOver
+	// EventRematchCountdown is sent when the rematch countdown changes.
+	EventRematchCountdown
+	// EventOpponentLeft is sent when the other player leaves the match.
+	EventOpponentLeft
+	// EventDisconnected is sent when the client's connection is lost.
+	EventDisconnected
+	// EventTakebackRequested is sent when the other player requests a
+	// takeback.
+	EventTakebackRequested
+	// EventDrawOffered is sent when the other player offers a draw.
+	EventDrawOffered
+	// EventError is sent when the match rejects a message sent by the
+	// client. The event's Err is a *MatchError.
+	EventError
+)
+
+// String satisfies the fmt.Stringer interface.
+func (typ EventType) String() string {
+	switch typ {
+	case EventMatchFound:
+		return "MatchFound"
+	case EventStateChanged:
+		return "StateChanged"
+	case EventYourTurn:
+		return "YourTurn"
+	case EventGameOver:
+		return "GameOver"
+	case EventRematchCountdown:
+		return "RematchCountdown"
+	case EventOpponentLeft:
+		return "OpponentLeft"
+	case EventDisconnected:
+		return "Disconnected"
+	case EventTakebackRequested:
+		return "TakebackRequested"
+	case EventDrawOffered:
+		return "DrawOffered"
+	case EventError:
+		return "Error"
+	}
+	return "Unknown"
+}
+
+// Event is a client event.
+type Event struct {
+	Type    EventType
+	MatchId string
+	State   *MatchState
+	Err     error
+}
+
+// Events returns the client's event stream. Events are queued, and are not
+// dropped when the receiver is slow. The channel is closed when the client is
+// closed.
+func (cl *Client) Events() <-chan Event {
+	cl.em.Lock()
+	defer cl.em.Unlock()
+	if cl.events == nil {
+		cl.events = make(chan Event)
+		cl.notify = make(chan struct{}, 1)
+		cl.done = make(chan struct{})
+		go cl.pump(cl.events, cl.notify, cl.done)
+	}
+	return cl.events
+}
+
+// emit queues an event, when the event stream is in use.
+func (cl *Client) emit(typ EventType, matchId string, state *MatchState, err error) {
+	cl.em.Lock()
+	defer cl.em.Unlock()
+	if cl.events == nil {
+		return
+	}
+	cl.queue = append(cl.queue, Event{
+		Type:    typ,
+		MatchId: matchId,
+		State:   state,
+		Err:     err,
+	})
+	select {
+	case cl.notify <- struct{}{}:
+	default:
+	}
+}
+
+// emitState queues the events for the transition from prev to state.
+func (cl *Client) emitState(matchId string, prev, state *MatchState) {
+	if state == nil || state.State == nil {
+		return
+	}
+	cl.emit(EventStateChanged, matchId, state, nil)
+	over := state.State.Winner != 0 || state.State.Draw
+	prevOver := prev != nil && prev.State != nil && (prev.State.Winner != 0 || prev.State.Draw)
+	switch {
+	case over && !prevOver:
+		cl.emit(EventGameOver, matchId, state, nil)
+	case !over && state.YourTurn && (prev == nil || !prev.YourTurn || prevOver):
+		cl.emit(EventYourTurn, matchId, state, nil)
+	}
+	if p := state.State.Takeback; cl.other(state, p) && (prev == nil || prev.State == nil || prev.State.Takeback != p) {
+		cl.emit(EventTakebackRequested, matchId, state, nil)
+	}
+	if p := state.State.DrawOffer; cl.other(state, p) && (prev == nil || prev.State == nil || prev.State.DrawOffer != p) {
+		cl.emit(EventDrawOffered, matchId, state, nil)
+	}
+	if state.State.RematchCountdown != 0 && (prev == nil || prev.State == nil || prev.State.RematchCountdown != state.State.RematchCountdown) {
+		cl.emit(EventRematchCountdown, matchId, state, nil)
+	}
+}
+
+// other returns true when player p is the client's opponent.
+func (cl *Client) other(state *MatchState, p int) bool {
+	return !state.Spectator && 0 < p && p <= len(state.State.Players) && state.State.Players[p-1].UserId != cl.userId
+}
+
+// pump delivers queued events to the events channel, until done is closed.
+func (cl *Client) pump(events chan Event, notify, done chan struct{}) {
+	defer close(events)
+	for {
+		cl.em.Lock()
+		queue := cl.queue
+		cl.queue = nil
+		cl.em.Unlock()
+		for _, ev := range queue {
+			select {
+			case events <- ev:
+			case <-done:
+				return
+			}
+		}
+		select {
+		case <-notify:
+		case <-done:
+			return
+		}
+	}
+}
+
+// stopEvents stops the event stream.
+func (cl *Client) stopEvents() {
+	cl.em.Lock()
+	defer cl.em.Unlock()
+	if cl.done != nil {
+		close(cl.done)
+		cl.events, cl.notify, cl.done, cl.queue = nil, nil, nil, nil
+	}
+}
@@ -0,0 +1,81 @@
+package xoxo
+
+import (
+	"fmt"
+)
+
+// RecordedMove is an accepted move in a recorded game.
+type RecordedMove struct {
+	// Tick is the match tick the move was accepted.
+	Tick   int64  `json:"tick"`
+	UserId string `json:"user_id"`
+	Move   Move   `json:"move"`
+	// Hash is the hash of the state after the move.
+	Hash string `json:"hash"`
+}
+
+// GameRecord is a recorded game.
+type GameRecord struct {
+	Variant Variant  `json:"variant"`
+	Players []Player `json:"players"`
+	// First is the player that moved first.
+	First  int            `json:"first,omitempty"`
+	Moves  []RecordedMove `json:"moves,omitempty"`
+	Winner Winner         `json:"winner,omitempty"`
+	Draw   bool           `json:"draw,omitempty"`
+	Reason Reason         `json:"reason,omitempty"`
+}
+
+// Replay replays the recorded moves, returning the state after each move,
+// starting with the initial state. Returns an error when a move is invalid or
+// does not match its recorded hash.
+func (g *GameRecord) Replay() ([]*State, error) {
+	state, err := NewVariantState(g.Variant)
+	if err != nil {
+		return nil, err
+	}
+	state.Players = append([]Player(nil), g.Players...)
+	if g.First != 0 {
+		state.PlayerTurn = g.First
+	}
+	states := []*State{state.Copy()}
+	for i, m := range g.Moves {
+		if err := state.Move(m.UserId, m.Move); err != nil {
+			return nil, fmt.Errorf("move %d: %w", i+1, err)
+		}
+		if hash := state.Hash(); hash != m.Hash {
+			return nil, fmt.Errorf("move %d: hash %s != %s", i+1, hash, m.Hash)
+		}
+		states = append(states, state.Copy())
+	}
+	return states, nil
+}
+
+// MatchRecord is the record of the games played in a match.
+type MatchRecord struct {
+	MatchId string       `json:"match_id"`
+	Games   []GameRecord `json:"games"`
+}
+
+// HistoryRequest is the request for the history RPC.
+type HistoryRequest struct {
+	Limit  int    `json:"limit,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// HistoryResponse is the response for the history RPC. The games of the
+// listed matches do not include their moves.
+type HistoryResponse struct {
+	Matches []MatchRecord `json:"matches"`
+	Cursor  string        `json:"cursor,omitempty"`
+}
+
+// ReplayRequest is the request for the replay RPC.
+type ReplayRequest struct {
+	MatchId string `json:"match_id"`
+}
+
+// ReplayResponse is the response for the replay RPC.
+type ReplayResponse struct {
+	Match MatchRecord `json:"match"`
+}
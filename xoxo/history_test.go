@@ -0,0 +1,45 @@
+package xoxo_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestReplay(t *testing.T) {
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	record := xoxo.GameRecord{
+		Variant: state.Variant,
+		Players: state.Players,
+	}
+	for i, m := range [][]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}} {
+		userId, move := strconv.Itoa(i%2), xoxo.NewMove(m[0], m[1])
+		if err := state.Move(userId, move); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		record.Moves = append(record.Moves, xoxo.RecordedMove{
+			Tick:   int64(i),
+			UserId: userId,
+			Move:   move,
+			Hash:   state.Hash(),
+		})
+	}
+	states, err := record.Replay()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(states) != 6 {
+		t.Fatalf("expected %d states, got: %d", 6, len(states))
+	}
+	if states[0].Hash() == states[1].Hash() {
+		t.Errorf("expected initial state hash to differ")
+	}
+	if s := states[5]; s.Winner != 1 || s.String() != state.String() {
+		t.Errorf("expected %s, got: %s", state, s)
+	}
+	record.Moves[2].Hash = record.Moves[3].Hash
+	if _, err := record.Replay(); err == nil {
+		t.Errorf("expected hash mismatch error")
+	}
+}
@@ -0,0 +1,511 @@
+package xoxo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rs/xid"
+)
+
+// Local is a game session played on the device, without a server, by two
+// players taking turns (hot seat) or by a player against an AI.
+//
+// Local games are casual, permitting takebacks, which are granted
+// immediately. The AI declines draw offers, and always agrees to a rematch.
+type Local struct {
+	mode     Mode
+	userId   string
+	username string
+	logf     func(string, ...interface{})
+	ai       Strategy
+
+	open    bool
+	variant Variant
+	bestOf  int
+	seq     int64
+	state   *State
+	view    *MatchState
+	changed chan struct{}
+	// ctx is the context of the AI's moves, canceled when leaving the match,
+	// as the contexts of the calls prompting them may be short lived.
+	ctx    context.Context
+	cancel func()
+
+	rw sync.RWMutex
+
+	connectHandler func(context.Context)
+	stateHandler   func(context.Context)
+}
+
+// NewLocal creates a local session for the hot seat or AI mode.
+func NewLocal(mode Mode, opts ...LocalOption) (*Local, error) {
+	if mode != ModeHotSeat && mode != ModeAI {
+		return nil, fmt.Errorf("invalid local mode %q", mode)
+	}
+	l := &Local{
+		mode:    mode,
+		logf:    func(string, ...interface{}) {},
+		ai:      NewGreedy(nil),
+		changed: make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(l)
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	if l.userId == "" {
+		l.userId = uuid.New().String()
+	}
+	if l.username == "" {
+		l.username = xid.New().String()
+	}
+	return l, nil
+}
+
+// Open opens the session. Local sessions are always connected.
+func (l *Local) Open(ctx context.Context) error {
+	l.rw.Lock()
+	l.open = true
+	l.rw.Unlock()
+	if l.connectHandler != nil {
+		l.connectHandler(ctx)
+	}
+	return nil
+}
+
+func (l *Local) Close() error {
+	_ = l.Leave(context.Background())
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	l.open = false
+	return nil
+}
+
+func (l *Local) Connected() bool {
+	l.rw.RLock()
+	defer l.rw.RUnlock()
+	return l.open
+}
+
+// Mode returns the session's mode.
+func (l *Local) Mode() Mode {
+	return l.mode
+}
+
+func (l *Local) State() *MatchState {
+	l.rw.RLock()
+	defer l.rw.RUnlock()
+	return l.view
+}
+
+// Ready waits until a game is ready to be played, returning false when the
+// context is done or the match is finished.
+func (l *Local) Ready(ctx context.Context) bool {
+	for {
+		l.rw.RLock()
+		state, changed := l.view, l.changed
+		l.rw.RUnlock()
+		switch {
+		case state == nil:
+		case state.State.Finished:
+			return false
+		case state.State.PlayerTurn == 1 || state.State.PlayerTurn == 2:
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// Next waits until it is the player's turn, returning false when the context
+// is done or the game is over.
+func (l *Local) Next(ctx context.Context) bool {
+	for {
+		l.rw.RLock()
+		state, changed := l.view, l.changed
+		l.rw.RUnlock()
+		switch {
+		case state == nil:
+		case state.State.Winner != 0, state.State.Draw:
+			return false
+		case state.YourTurn:
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// Join starts a new match, using the variant and series length of the
+// options. Other options are ignored.
+func (l *Local) Join(ctx context.Context, opts ...JoinOption) error {
+	l.logf("Join: starting %s match", l.mode)
+	o := &joinOptions{
+		stringProps:  make(map[string]string),
+		numericProps: make(map[string]float64),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	variant, bestOf := DefaultVariant, int(o.numericProps[PropBestOf])
+	if o.variant != nil {
+		variant = *o.variant
+	}
+	if err := ValidBestOf(bestOf); err != nil {
+		return err
+	}
+	state, err := NewVariantState(variant)
+	if err != nil {
+		return err
+	}
+	switch l.mode {
+	case ModeHotSeat:
+		_ = state.Add("", "", l.userId, "Player 1")
+		_ = state.Add("", "", uuid.New().String(), "Player 2")
+	case ModeAI:
+		_ = state.Add("", "", l.userId, l.username)
+		_ = state.AddBot(uuid.New().String(), "AI")
+	}
+	state.Casual, state.Series = true, NewSeries(bestOf)
+	l.rw.Lock()
+	if l.state != nil && !l.state.Finished {
+		l.rw.Unlock()
+		return fmt.Errorf("already playing a match")
+	}
+	l.variant, l.bestOf, l.state = variant, bestOf, state
+	l.update()
+	l.rw.Unlock()
+	l.notify(ctx)
+	return nil
+}
+
+func (l *Local) JoinAsync(ctx context.Context, f func(error), opts ...JoinOption) {
+	go func() {
+		if err := l.Join(ctx, opts...); f != nil {
+			f(err)
+		}
+	}()
+}
+
+func (l *Local) Leave(ctx context.Context) error {
+	l.logf("Leave: leaving match")
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	l.state, l.view = nil, nil
+	// the sequence number changes, discarding any pending AI move
+	l.seq++
+	l.cancel()
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	l.change()
+	return nil
+}
+
+func (l *Local) LeaveAsync(ctx context.Context, f func(error)) {
+	go func() {
+		if err := l.Leave(ctx); f != nil {
+			f(err)
+		}
+	}()
+}
+
+// Move moves for the player to move. In the AI mode, the AI's reply is
+// played asynchronously.
+func (l *Local) Move(ctx context.Context, row, col int) error {
+	l.logf("Move: moving %d, %d", row, col)
+	return l.do(ctx, func(p int) error {
+		if err := l.state.Move(l.state.Players[p-1].UserId, NewMove(row, col)); err != nil {
+			return err
+		}
+		if l.state.Winner != 0 || l.state.Draw {
+			l.end()
+		}
+		return nil
+	})
+}
+
+func (l *Local) MoveAsync(ctx context.Context, row, col int, f func(error)) {
+	go func() {
+		if err := l.Move(ctx, row, col); f != nil {
+			f(err)
+		}
+	}()
+}
+
+// RequestTakeback takes back the last move in the hot seat mode, or the
+// player's last move in the AI mode.
+func (l *Local) RequestTakeback(ctx context.Context) error {
+	l.logf("RequestTakeback: requesting takeback")
+	return l.do(ctx, func(p int) error {
+		if len(l.state.Moves) == 0 {
+			return fmt.Errorf("%w: no move to take back", ErrNotAllowed)
+		}
+		last := l.state.Moves[len(l.state.Moves)-1]
+		if l.mode == ModeHotSeat {
+			p = l.state.Cells[last.Row-1][last.Col-1]
+		}
+		moved := false
+		for _, m := range l.state.Moves {
+			moved = moved || l.state.Cells[m.Row-1][m.Col-1] == p
+		}
+		if !moved {
+			return fmt.Errorf("%w: player %d has no move to take back", ErrNotAllowed, p)
+		}
+		for {
+			if err := l.state.Undo(); err != nil {
+				return err
+			}
+			if l.state.PlayerTurn == p {
+				return nil
+			}
+		}
+	})
+}
+
+// RespondTakeback returns an error, as takebacks are granted immediately.
+func (l *Local) RespondTakeback(ctx context.Context, accept bool) error {
+	return fmt.Errorf("%w: no takeback requested", ErrNotAllowed)
+}
+
+// Resign resigns the game for the player to move, in the hot seat mode, or
+// the player, in the AI mode.
+func (l *Local) Resign(ctx context.Context) error {
+	l.logf("Resign: resigning")
+	return l.do(ctx, func(p int) error {
+		if err := l.state.Resign(p); err != nil {
+			return err
+		}
+		l.end()
+		return nil
+	})
+}
+
+// OfferDraw offers a draw for the player to move, in the hot seat mode. The AI
+// declines draw offers.
+func (l *Local) OfferDraw(ctx context.Context) error {
+	l.logf("OfferDraw: offering draw")
+	return l.do(ctx, func(p int) error {
+		switch {
+		case l.state.DrawOffer != 0:
+			return fmt.Errorf("%w: player %d already offered a draw", ErrNotAllowed, l.state.DrawOffer)
+		case l.mode == ModeHotSeat:
+			l.state.DrawOffer = p
+		}
+		return nil
+	})
+}
+
+// RespondDraw accepts or declines the pending draw offer.
+func (l *Local) RespondDraw(ctx context.Context, accept bool) error {
+	l.logf("RespondDraw: accept %t", accept)
+	return l.do(ctx, func(int) error {
+		switch {
+		case l.state.DrawOffer == 0:
+			return fmt.Errorf("%w: no draw offered", ErrNotAllowed)
+		case !accept:
+			l.state.DrawOffer = 0
+			return nil
+		}
+		l.state.Draw, l.state.Reason, l.state.PlayerTurn = true, ReasonAgreed, -1
+		l.state.DrawOffer, l.state.Takeback = 0, 0
+		l.end()
+		return nil
+	})
+}
+
+// Rematch starts the next game, with the other player moving first, or
+// finishes the match when accept is false.
+func (l *Local) Rematch(ctx context.Context, accept bool) error {
+	l.logf("Rematch: accept %t", accept)
+	l.rw.Lock()
+	switch {
+	case l.state == nil:
+		l.rw.Unlock()
+		return fmt.Errorf("no active match")
+	case len(l.state.Rematch) != 2:
+		l.rw.Unlock()
+		return fmt.Errorf("%w: no rematch pending", ErrNotAllowed)
+	case !accept:
+		l.state.Finished, l.state.Rematch = true, nil
+	default:
+		players, series := l.state.Players, l.state.Series
+		l.state, _ = NewVariantState(l.variant)
+		l.state.Players, l.state.Series, l.state.Casual = players, series, true
+		l.state.PlayerTurn = 1 + series.Games%2
+	}
+	l.update()
+	seq, ai, aiCtx := l.seq, l.aiState(), l.ctx
+	l.rw.Unlock()
+	l.notify(ctx)
+	if ai != nil {
+		go l.aiMove(aiCtx, seq, ai)
+	}
+	return nil
+}
+
+// do runs f for the acting player while holding the lock, when a game is in
+// progress. Starts the AI's move when it becomes the AI's turn.
+func (l *Local) do(ctx context.Context, f func(int) error) error {
+	l.rw.Lock()
+	p := l.player()
+	switch {
+	case l.state == nil:
+		l.rw.Unlock()
+		return fmt.Errorf("no active match")
+	case l.state.PlayerTurn != 1 && l.state.PlayerTurn != 2:
+		l.rw.Unlock()
+		return fmt.Errorf("%w: game is not in progress", ErrGameOver)
+	}
+	if err := f(p); err != nil {
+		l.rw.Unlock()
+		return err
+	}
+	l.update()
+	seq, ai, aiCtx := l.seq, l.aiState(), l.ctx
+	l.rw.Unlock()
+	l.notify(ctx)
+	if ai != nil {
+		go l.aiMove(aiCtx, seq, ai)
+	}
+	return nil
+}
+
+// aiMove plays the AI's move for the state with the sequence number, unless
+// the game changes while the AI is choosing its move.
+func (l *Local) aiMove(ctx context.Context, seq int64, state *State) {
+	move, err := l.ai.ChooseMove(ctx, state)
+	if err != nil {
+		l.logf("unable to choose AI move: %v", err)
+		return
+	}
+	l.rw.Lock()
+	if l.seq != seq || l.state == nil {
+		l.rw.Unlock()
+		return
+	}
+	p := l.state.PlayerTurn
+	if err := l.state.Move(l.state.Players[p-1].UserId, move); err != nil {
+		l.rw.Unlock()
+		l.logf("unable to play AI move: %v", err)
+		return
+	}
+	if l.state.Winner != 0 || l.state.Draw {
+		l.end()
+	}
+	l.update()
+	l.rw.Unlock()
+	l.notify(ctx)
+}
+
+// end ends the game, finishing the match when the series is over. Must be
+// called while holding the write lock.
+func (l *Local) end() {
+	l.state.Series.Add(l.state.Winner)
+	if l.state.Series.Over() {
+		l.state.Finished = true
+		return
+	}
+	l.state.Rematch = make([]bool, 2)
+	if l.mode == ModeAI {
+		l.state.Rematch[1] = true
+	}
+}
+
+// player returns the acting player, who is the player to move in the hot seat
+// mode, and player 1 in the AI mode. Must be called while holding the lock.
+func (l *Local) player() int {
+	if l.mode == ModeAI || l.state == nil {
+		return 1
+	}
+	return l.state.PlayerTurn
+}
+
+// aiState returns a copy of the state for the AI to choose its move, or nil
+// when it is not the AI's turn. Must be called while holding the lock.
+func (l *Local) aiState() *State {
+	if l.mode != ModeAI || l.state == nil || l.state.PlayerTurn != 2 {
+		return nil
+	}
+	return l.state.Copy()
+}
+
+// update updates the session's view of the match state, notifying waiters.
+// Must be called while holding the write lock.
+func (l *Local) update() {
+	l.seq++
+	s := l.state.Copy()
+	view := &MatchState{
+		State:    s,
+		YourTurn: s.PlayerTurn == 1 || (l.mode == ModeHotSeat && s.PlayerTurn == 2),
+		Seq:      l.seq,
+	}
+	view.ActivePlayer, view.OtherPlayer = &s.Players[0], &s.Players[1]
+	if s.PlayerTurn == 2 {
+		view.ActivePlayer, view.OtherPlayer = view.OtherPlayer, view.ActivePlayer
+	}
+	l.view = view
+	l.change()
+}
+
+// change notifies waiters of a change to the session's state. Must be called
+// while holding the write lock.
+func (l *Local) change() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// notify calls the state handler. Must not be called while holding the lock.
+func (l *Local) notify(ctx context.Context) {
+	if l.stateHandler != nil {
+		l.stateHandler(ctx)
+	}
+}
+
+// LocalOption is a local session option.
+type LocalOption func(*Local)
+
+// WithLocalLogf is a local session option to set the log func.
+func WithLocalLogf(logf func(string, ...interface{})) LocalOption {
+	return func(l *Local) {
+		l.logf = logf
+	}
+}
+
+// WithLocalUsername is a local session option to set the player's username,
+// used in the AI mode.
+func WithLocalUsername(username string) LocalOption {
+	return func(l *Local) {
+		l.username = username
+	}
+}
+
+// WithLocalAI is a local session option to set the AI's strategy. The
+// default strategy is Greedy.
+func WithLocalAI(strategy Strategy) LocalOption {
+	return func(l *Local) {
+		l.ai = strategy
+	}
+}
+
+// WithLocalHandler is a local session option to set the connect and state
+// handlers, as with WithHandler.
+func WithLocalHandler(handler interface{}) LocalOption {
+	return func(l *Local) {
+		if x, ok := handler.(interface {
+			ConnectHandler(context.Context)
+		}); ok {
+			l.connectHandler = x.ConnectHandler
+		}
+		if x, ok := handler.(interface {
+			StateHandler(context.Context)
+		}); ok {
+			l.stateHandler = x.StateHandler
+		}
+	}
+}
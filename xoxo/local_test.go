@@ -0,0 +1,217 @@
+package xoxo_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestLocalHotSeat(t *testing.T) {
+	ctx := context.Background()
+	l, err := xoxo.NewLocal(xoxo.ModeHotSeat)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Open(ctx); err != nil || !l.Connected() {
+		t.Fatalf("expected open session, got: %v", err)
+	}
+	if err := l.Join(ctx, xoxo.WithJoinBestOf(3)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for i, m := range [][]int{{0, 0}, {1, 0}, {0, 1}, {2, 2}} {
+		if !l.Next(ctx) {
+			t.Fatalf("move %d: expected turn", i)
+		}
+		if err := l.Move(ctx, m[0], m[1]); err != nil {
+			t.Fatalf("move %d: expected no error, got: %v", i, err)
+		}
+	}
+	// player 2 takes back their last move
+	if err := l.RequestTakeback(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if state := l.State(); state.State.PlayerTurn != 2 || len(state.State.Moves) != 3 || !state.YourTurn {
+		t.Fatalf("expected player 2 to move, got: %s", state.State)
+	}
+	if err := l.Move(ctx, 1, 1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Move(ctx, 0, 2); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	state := l.State()
+	if state.State.Winner != 1 || len(state.State.Rematch) != 2 || l.Next(ctx) {
+		t.Fatalf("expected player 1 to win, got: %s", state.State)
+	}
+	if err := l.Move(ctx, 2, 2); !errors.Is(err, xoxo.ErrGameOver) {
+		t.Errorf("expected ErrGameOver, got: %v", err)
+	}
+	if err := l.Rematch(ctx, true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if state := l.State(); state.State.PlayerTurn != 2 || state.State.Series.Games != 1 || !l.Ready(ctx) {
+		t.Fatalf("expected player 2 to move first, got: %s", state.State)
+	}
+	// player 2 offers a draw, which player 1 accepts
+	if err := l.OfferDraw(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.RespondDraw(ctx, true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Rematch(ctx, true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Resign(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	state = l.State()
+	if state.State.Winner != 2 || !state.State.Finished || l.Ready(ctx) {
+		t.Errorf("expected finished series, got: %s %s", state.State, state.State.Series)
+	}
+	if s, exp := state.State.Series.String(), "1-1-1 (best of 3)"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if err := l.Close(); err != nil || l.State() != nil || l.Connected() {
+		t.Errorf("expected closed session, got: %v", err)
+	}
+}
+
+func TestLocalAI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	variant, _ := xoxo.ParseVariant("4x4x3")
+	l, err := xoxo.NewLocal(xoxo.ModeAI)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Join(ctx, xoxo.WithJoinVariant(variant)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r := rand.New(rand.NewSource(0))
+	for games := 0; games < 3; games++ {
+		if !l.Ready(ctx) {
+			t.Fatalf("game %d: expected game ready", games)
+		}
+		for l.Next(ctx) {
+			state := l.State()
+			if state.State.PlayerTurn != 1 || state.ActivePlayer.Bot {
+				t.Fatalf("expected player's turn, got: %s", state.State)
+			}
+			v := state.State.Available()
+			n := r.Intn(len(v))
+			if err := l.Move(ctx, v[n][0], v[n][1]); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+		state := l.State()
+		if state.State.Winner == 0 && !state.State.Draw {
+			t.Fatalf("game %d: expected game over, got: %s", games, state.State)
+		}
+		if state.State.Rematch == nil || !state.State.Rematch[1] {
+			t.Fatalf("game %d: expected AI rematch vote, got: %v", games, state.State.Rematch)
+		}
+		if err := l.Rematch(ctx, true); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	// the AI moves first in even games, and declines draws
+	if !l.Next(ctx) || len(l.State().State.Moves) != 1 {
+		t.Fatalf("expected AI to move first, got: %s", l.State().State)
+	}
+	if err := l.OfferDraw(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if state := l.State(); state.State.Draw || state.State.DrawOffer != 0 {
+		t.Errorf("expected AI to decline draw, got: %s", state.State)
+	}
+	if err := l.RequestTakeback(ctx); !errors.Is(err, xoxo.ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed, got: %v", err)
+	}
+	if _, err := xoxo.NewLocal(xoxo.ModeOnline); err == nil {
+		t.Errorf("expected error for online mode")
+	}
+}
+
+func TestLocalAILeave(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	entered, release := make(chan struct{}), make(chan struct{})
+	ai := xoxo.StrategyFunc(func(ctx context.Context, state *xoxo.State) (xoxo.Move, error) {
+		entered <- struct{}{}
+		<-release
+		v := state.Available()
+		return xoxo.NewMove(v[0][0], v[0][1]), nil
+	})
+	l, err := xoxo.NewLocal(xoxo.ModeAI, xoxo.WithLocalAI(ai))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// a pending AI move is not played into the next match
+	if err := l.Join(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Move(ctx, 1, 1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	<-entered
+	if err := l.Leave(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Join(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+	if state := l.State(); len(state.State.Moves) != 0 || state.State.PlayerTurn != 1 {
+		t.Errorf("expected no moves, got: %s", state.State)
+	}
+	// the AI moves after the context of the player's move is canceled
+	l, err = xoxo.NewLocal(xoxo.ModeAI, xoxo.WithLocalAI(xoxo.StrategyFunc(func(ctx context.Context, state *xoxo.State) (xoxo.Move, error) {
+		time.Sleep(10 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			return xoxo.Move{}, err
+		}
+		v := state.Available()
+		return xoxo.NewMove(v[0][0], v[0][1]), nil
+	})))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := l.Join(ctx); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	moveCtx, moveCancel := context.WithCancel(ctx)
+	if err := l.Move(moveCtx, 1, 1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	moveCancel()
+	for l.Next(ctx) && len(l.State().State.Moves) != 2 {
+	}
+	if state := l.State(); len(state.State.Moves) != 2 {
+		t.Errorf("expected AI move, got: %s", state.State)
+	}
+	// leaving while the AI is about to move
+	l, err = xoxo.NewLocal(xoxo.ModeAI)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		if err := l.Join(ctx); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := l.Move(ctx, 1, 1); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := l.Leave(ctx); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if i%2 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package xoxo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// DefaultIterations is the default number of MCTS iterations per move.
+const DefaultIterations = 2000
+
+// MCTS is a Monte Carlo tree search strategy, choosing the most visited move
+// after running the iterations, each selecting a line with UCT and scoring it
+// with a random playout.
+type MCTS struct {
+	iterations int
+	r          *rand.Rand
+	mu         sync.Mutex
+}
+
+// NewMCTS creates a Monte Carlo tree search strategy running the iterations
+// per move, using r for its playouts, or a time seeded source when r is nil.
+func NewMCTS(iterations int, r *rand.Rand) *MCTS {
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	return &MCTS{
+		iterations: iterations,
+		r:          newRand(r),
+	}
+}
+
+// ChooseMove satisfies the Strategy interface. When the context is done
+// before all iterations have run, the most visited move so far is chosen.
+func (s *MCTS) ChooseMove(ctx context.Context, state *State) (Move, error) {
+	switch {
+	case state.Winner != 0 || state.Draw || (state.PlayerTurn != 1 && state.PlayerTurn != 2):
+		return Move{}, fmt.Errorf("%w: game is not in progress", ErrGameOver)
+	case len(state.Available()) == 0:
+		return Move{}, fmt.Errorf("no available moves")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root := newMCTSNode(nil, -1, 3-state.PlayerTurn, state.Cells)
+	cells := copyCells(state.Cells)
+	for i := 0; i < s.iterations; i++ {
+		if i%64 == 0 && ctx.Err() != nil && len(root.children) != 0 {
+			break
+		}
+		for r := range cells {
+			copy(cells[r], state.Cells[r])
+		}
+		s.iterate(root, cells, state.K)
+	}
+	best := root.children[0]
+	for _, c := range root.children[1:] {
+		if c.visits > best.visits {
+			best = c
+		}
+	}
+	cols := len(state.Cells[0])
+	return NewMove(best.cell/cols, best.cell%cols), nil
+}
+
+// iterate runs an iteration from the root, playing the moves on cells.
+func (s *MCTS) iterate(root *mctsNode, cells [][]int, k int) {
+	cols := len(cells[0])
+	n := root
+	// select
+	for len(n.untried) == 0 && len(n.children) != 0 {
+		n = n.best()
+		cells[n.cell/cols][n.cell%cols] = n.player
+	}
+	// expand
+	if n.winner == 0 && len(n.untried) != 0 {
+		j := s.r.Intn(len(n.untried))
+		i := n.untried[j]
+		n.untried[j] = n.untried[len(n.untried)-1]
+		n.untried = n.untried[:len(n.untried)-1]
+		p := 3 - n.player
+		cells[i/cols][i%cols] = p
+		c := newMCTSNode(n, i, p, cells)
+		if isWinner(p, cells, i/cols, i%cols, k) {
+			c.winner, c.untried = p, nil
+		}
+		n.children = append(n.children, c)
+		n = c
+	}
+	// simulate
+	winner := n.winner
+	if winner == 0 {
+		var free []int
+		for i := 0; i < len(cells)*cols; i++ {
+			if cells[i/cols][i%cols] == -1 {
+				free = append(free, i)
+			}
+		}
+		for p := 3 - n.player; winner == 0 && len(free) != 0; p = 3 - p {
+			j := s.r.Intn(len(free))
+			i := free[j]
+			free[j] = free[len(free)-1]
+			free = free[:len(free)-1]
+			cells[i/cols][i%cols] = p
+			if isWinner(p, cells, i/cols, i%cols, k) {
+				winner = p
+			}
+		}
+	}
+	// backpropagate
+	for ; n != nil; n = n.parent {
+		n.visits++
+		switch winner {
+		case n.player:
+			n.score += 1
+		case 0:
+			n.score += 0.5
+		}
+	}
+}
+
+// mctsNode is a node of the search tree.
+type mctsNode struct {
+	parent   *mctsNode
+	children []*mctsNode
+	untried  []int
+	// cell is the cell played by player to reach the node.
+	cell   int
+	player int
+	// winner is set when player won with the move.
+	winner int
+	visits int
+	score  float64
+}
+
+// newMCTSNode creates a node for the position, with the empty cells untried.
+func newMCTSNode(parent *mctsNode, cell, player int, cells [][]int) *mctsNode {
+	n := &mctsNode{
+		parent: parent,
+		cell:   cell,
+		player: player,
+	}
+	cols := len(cells[0])
+	for i := 0; i < len(cells)*cols; i++ {
+		if cells[i/cols][i%cols] == -1 {
+			n.untried = append(n.untried, i)
+		}
+	}
+	return n
+}
+
+// best returns the child with the highest upper confidence bound.
+func (n *mctsNode) best() *mctsNode {
+	var best *mctsNode
+	max, logVisits := math.Inf(-1), math.Log(float64(n.visits))
+	for _, c := range n.children {
+		ucb := c.score/float64(c.visits) + math.Sqrt2*math.Sqrt(logVisits/float64(c.visits))
+		if ucb > max {
+			best, max = c, ucb
+		}
+	}
+	return best
+}
@@ -0,0 +1,48 @@
+package xoxo
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MetaProtocol is the match join metadata key with the client's protocol
+// version.
+const MetaProtocol = "protocol"
+
+// Protocol versions.
+//
+// Version 1 clients, which do not send a protocol version, decode the match
+// state strictly, and are only sent full JSON match states with the version 1
+// fields. Their moves are not checked against the state sequence number, and
+// they can only play the default variant. Version 2 adds error messages, state
+// sequence numbers, deltas and codecs.
+const (
+	ProtocolVersion    = 2
+	MinProtocolVersion = 1
+)
+
+// ParseProtocol parses the protocol version from the match join metadata
+// value, returning version 1 when the value is empty.
+func ParseProtocol(str string) (int, error) {
+	if str == "" {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(str)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid protocol version %q", str)
+	}
+	return version, nil
+}
+
+// NegotiateProtocol returns the protocol version to use with a client
+// supporting version. Clients newer than ProtocolVersion are downgraded to
+// ProtocolVersion. Returns an error when the client is too old.
+func NegotiateProtocol(version int) (int, error) {
+	switch {
+	case version < MinProtocolVersion:
+		return 0, fmt.Errorf("protocol version %d is not supported, minimum version is %d", version, MinProtocolVersion)
+	case version > ProtocolVersion:
+		return ProtocolVersion, nil
+	}
+	return version, nil
+}
@@ -0,0 +1,45 @@
+package xoxo_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestProtocol(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp int
+		err bool
+	}{
+		{"", 1, false},
+		{"1", 1, false},
+		{strconv.Itoa(xoxo.ProtocolVersion), xoxo.ProtocolVersion, false},
+		{strconv.Itoa(xoxo.ProtocolVersion + 1), xoxo.ProtocolVersion, false},
+		{"0", 0, true},
+		{"v2", 0, true},
+	}
+	for i, test := range tests {
+		version, err := xoxo.ParseProtocol(test.s)
+		if err == nil {
+			version, err = xoxo.NegotiateProtocol(version)
+		}
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error", i)
+		case !test.err && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		case version != test.exp:
+			t.Errorf("test %d expected %d, got: %d", i, test.exp, version)
+		}
+	}
+	// unknown fields are ignored
+	var state xoxo.MatchState
+	if err := state.Unmarshal([]byte(`{"your_turn":true,"seq":3,"added_later":{"x":1}}`)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !state.YourTurn || state.Seq != 3 {
+		t.Errorf("expected decoded state, got: %+v", state)
+	}
+}
@@ -0,0 +1,58 @@
+package xoxo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Session is a game session, played over the network with Client, or on the
+// device with Local.
+type Session interface {
+	Open(context.Context) error
+	Close() error
+	Connected() bool
+	State() *MatchState
+	Ready(context.Context) bool
+	Next(context.Context) bool
+	Join(context.Context, ...JoinOption) error
+	JoinAsync(context.Context, func(error), ...JoinOption)
+	Leave(context.Context) error
+	LeaveAsync(context.Context, func(error))
+	Move(ctx context.Context, row, col int) error
+	MoveAsync(ctx context.Context, row, col int, f func(error))
+	RequestTakeback(context.Context) error
+	RespondTakeback(ctx context.Context, accept bool) error
+	Resign(context.Context) error
+	OfferDraw(context.Context) error
+	RespondDraw(ctx context.Context, accept bool) error
+	Rematch(ctx context.Context, accept bool) error
+}
+
+var (
+	_ Session = (*Client)(nil)
+	_ Session = (*Local)(nil)
+)
+
+// Mode is a game session mode.
+type Mode string
+
+// Modes.
+const (
+	// ModeOnline plays against other players through the Nakama server.
+	ModeOnline Mode = "online"
+	// ModeHotSeat plays two players taking turns on the same device.
+	ModeHotSeat Mode = "hotseat"
+	// ModeAI plays against an AI on the device.
+	ModeAI Mode = "ai"
+)
+
+// ParseMode parses the session mode, returning ModeOnline when str is empty.
+func ParseMode(str string) (Mode, error) {
+	switch mode := Mode(str); mode {
+	case "":
+		return ModeOnline, nil
+	case ModeOnline, ModeHotSeat, ModeAI:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid mode %q", str)
+}
@@ -0,0 +1,100 @@
+package xoxo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Strategy chooses the move for the player to move.
+type Strategy interface {
+	ChooseMove(context.Context, *State) (Move, error)
+}
+
+// StrategyFunc wraps a func as a Strategy.
+type StrategyFunc func(context.Context, *State) (Move, error)
+
+// ChooseMove satisfies the Strategy interface.
+func (f StrategyFunc) ChooseMove(ctx context.Context, state *State) (Move, error) {
+	return f(ctx, state)
+}
+
+// Random is a strategy playing random moves.
+type Random struct {
+	r  *rand.Rand
+	mu sync.Mutex
+}
+
+// NewRandom creates a random strategy using r, or a time seeded source when r
+// is nil.
+func NewRandom(r *rand.Rand) *Random {
+	return &Random{
+		r: newRand(r),
+	}
+}
+
+// ChooseMove satisfies the Strategy interface.
+func (s *Random) ChooseMove(ctx context.Context, state *State) (Move, error) {
+	v := state.Available()
+	if len(v) == 0 {
+		return Move{}, fmt.Errorf("no available moves")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.r.Intn(len(v))
+	return NewMove(v[n][0], v[n][1]), nil
+}
+
+// Greedy is a strategy playing a winning move, or blocking the other player's
+// winning move, or otherwise playing a random move.
+type Greedy struct {
+	random *Random
+}
+
+// NewGreedy creates a greedy strategy using r for its random moves, or a time
+// seeded source when r is nil.
+func NewGreedy(r *rand.Rand) *Greedy {
+	return &Greedy{
+		random: NewRandom(r),
+	}
+}
+
+// ChooseMove satisfies the Strategy interface.
+func (s *Greedy) ChooseMove(ctx context.Context, state *State) (Move, error) {
+	v := state.Available()
+	if len(v) == 0 {
+		return Move{}, fmt.Errorf("no available moves")
+	}
+	cells := copyCells(state.Cells)
+	p := state.PlayerTurn
+	for _, q := range []int{p, 3 - p} {
+		for _, c := range v {
+			cells[c[0]][c[1]] = q
+			win := isWinner(q, cells, c[0], c[1], state.K)
+			cells[c[0]][c[1]] = -1
+			if win {
+				return NewMove(c[0], c[1]), nil
+			}
+		}
+	}
+	return s.random.ChooseMove(ctx, state)
+}
+
+// newRand returns r, or a time seeded source when r is nil.
+func newRand(r *rand.Rand) *rand.Rand {
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return r
+}
+
+// copyCells returns a copy of the cells.
+func copyCells(cells [][]int) [][]int {
+	c := make([][]int, len(cells))
+	for i := range cells {
+		c[i] = append([]int(nil), cells[i]...)
+	}
+	return c
+}
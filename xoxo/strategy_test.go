@@ -0,0 +1,60 @@
+package xoxo_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestStrategies(t *testing.T) {
+	ctx := context.Background()
+	newState := func(moves ...[2]int) *xoxo.State {
+		state := newTwoPlayerState(t, xoxo.DefaultVariant)
+		for i, move := range moves {
+			if err := state.Move(strconv.Itoa(i%2), xoxo.NewMove(move[0], move[1])); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+		return state
+	}
+	tests := []struct {
+		name     string
+		strategy xoxo.Strategy
+		moves    [][2]int
+		exp      xoxo.Move
+	}{
+		{"greedy win", xoxo.NewGreedy(rand.New(rand.NewSource(0))), [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}}, xoxo.NewMove(0, 2)},
+		{"greedy block", xoxo.NewGreedy(rand.New(rand.NewSource(0))), [][2]int{{0, 0}, {1, 0}, {2, 2}, {1, 1}}, xoxo.NewMove(1, 2)},
+		{"mcts win", xoxo.NewMCTS(500, rand.New(rand.NewSource(0))), [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}}, xoxo.NewMove(0, 2)},
+		{"mcts block", xoxo.NewMCTS(500, rand.New(rand.NewSource(0))), [][2]int{{0, 0}, {1, 0}, {2, 2}, {1, 1}}, xoxo.NewMove(1, 2)},
+	}
+	for _, v := range tests {
+		test := v
+		t.Run(test.name, func(t *testing.T) {
+			move, err := test.strategy.ChooseMove(ctx, newState(test.moves...))
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if move != test.exp {
+				t.Errorf("expected move %v, got: %v", test.exp, move)
+			}
+		})
+	}
+	r := xoxo.NewRandom(rand.New(rand.NewSource(0)))
+	for state := newState(); state.Winner == 0 && !state.Draw; {
+		move, err := r.ChooseMove(ctx, state)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := state.Move(strconv.Itoa(len(state.Moves)%2), move); err != nil {
+			t.Fatalf("expected legal move, got: %v", err)
+		}
+	}
+	if _, err := xoxo.NewMCTS(0, nil).ChooseMove(ctx, newState([2]int{0, 0}, [2]int{1, 0}, [2]int{0, 1}, [2]int{1, 1}, [2]int{0, 2})); !errors.Is(err, xoxo.ErrGameOver) {
+		t.Errorf("expected ErrGameOver, got: %v", err)
+	}
+}
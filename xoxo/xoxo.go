@@ -4,12 +4,51 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"strconv"
+	"strings"
+
+	"github.com/ascii8/xoxo-go/rating"
+)
+
+const (
+	OpCodeMove             = 1
+	OpCodeState            = 2
+	OpCodeTakebackRequest  = 3
+	OpCodeTakebackResponse = 4
+	OpCodeResign           = 5
+	OpCodeDrawOffer        = 6
+	OpCodeDrawResponse     = 7
+	OpCodeRematch          = 8
+	OpCodeError            = 9
+	OpCodeDelta            = 10
+	OpCodeResync           = 11
+)
+
+// RPC ids.
+const (
+	RpcRatings       = "xoxo_ratings"
+	RpcCreatePrivate = "xoxo_create_private"
+	RpcJoinCode      = "xoxo_join_code"
+	RpcHistory       = "xoxo_history"
+	RpcReplay        = "xoxo_replay"
 )
 
+// LeaderboardRating is the id of the rating leaderboard.
+const LeaderboardRating = "xoxo_rating"
+
+// MetaSpectator is the match join metadata key requesting to join as a
+// spectator.
+const MetaSpectator = "spectator"
+
+// Matchmaker properties.
 const (
-	OpCodeMove  = 1
-	OpCodeState = 2
+	PropBot          = "bot"
+	PropVariant      = "variant"
+	PropRegion       = "region"
+	PropRating       = "rating"
+	PropRatingSpread = "rating_spread"
+	PropBestOf       = "best_of"
 )
 
 type Winner int
@@ -44,28 +83,315 @@ type Player struct {
 	SessionId string `json:"session_id,omitempty"`
 	UserId    string `json:"user_id,omitempty"`
 	Username  string `json:"username,omitempty"`
+	Bot       bool   `json:"bot,omitempty"`
+	// Disconnected is set while the player's seat is reserved for them to
+	// reconnect.
+	Disconnected bool `json:"disconnected,omitempty"`
+}
+
+// BotLevel is the difficulty level of a bot opponent.
+type BotLevel string
+
+// Bot levels.
+const (
+	BotEasy   BotLevel = "easy"
+	BotMedium BotLevel = "medium"
+	BotHard   BotLevel = "hard"
+)
+
+// ParseBotLevel parses a bot level.
+func ParseBotLevel(str string) (BotLevel, error) {
+	switch level := BotLevel(str); level {
+	case BotEasy, BotMedium, BotHard:
+		return level, nil
+	}
+	return "", fmt.Errorf("invalid bot level %q", str)
+}
+
+// Variant is a board variant, a Rows x Cols board won by placing K marks in
+// a row (horizontally, vertically, or diagonally).
+type Variant struct {
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+	K    int `json:"k"`
+}
+
+// DefaultVariant is the standard 3x3 Tic-Tac-Toe variant.
+var DefaultVariant = Variant{
+	Rows: 3,
+	Cols: 3,
+	K:    3,
+}
+
+// MaxBoardSize is the maximum number of rows or cols of a variant.
+const MaxBoardSize = 32
+
+// ParseVariant parses a variant in the form of "<rows>x<cols>x<k>" (for
+// example, "3x3x3" or "15x15x5").
+func ParseVariant(str string) (Variant, error) {
+	s := strings.Split(str, "x")
+	if len(s) != 3 {
+		return Variant{}, fmt.Errorf("invalid variant %q", str)
+	}
+	var n [3]int
+	for i := range s {
+		var err error
+		if n[i], err = strconv.Atoi(s[i]); err != nil {
+			return Variant{}, fmt.Errorf("invalid variant %q: %w", str, err)
+		}
+	}
+	v := Variant{Rows: n[0], Cols: n[1], K: n[2]}
+	if err := v.Valid(); err != nil {
+		return Variant{}, err
+	}
+	return v, nil
+}
+
+// Valid returns an error when the variant is not playable.
+func (v Variant) Valid() error {
+	switch {
+	case v.Rows < 1 || MaxBoardSize < v.Rows:
+		return fmt.Errorf("invalid rows %d", v.Rows)
+	case v.Cols < 1 || MaxBoardSize < v.Cols:
+		return fmt.Errorf("invalid cols %d", v.Cols)
+	case v.K < 1 || (v.Rows < v.K && v.Cols < v.K):
+		return fmt.Errorf("invalid k %d for %dx%d board", v.K, v.Rows, v.Cols)
+	}
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (v Variant) String() string {
+	return fmt.Sprintf("%dx%dx%d", v.Rows, v.Cols, v.K)
+}
+
+// Lines returns all K length lines on the board, as a list of (row, col)
+// coordinates.
+func (v Variant) Lines() [][][2]int {
+	var lines [][][2]int
+	for _, d := range directions {
+		for i := 0; i < v.Rows; i++ {
+			for j := 0; j < v.Cols; j++ {
+				ei, ej := i+d[0]*(v.K-1), j+d[1]*(v.K-1)
+				if ei < 0 || v.Rows <= ei || ej < 0 || v.Cols <= ej {
+					continue
+				}
+				line := make([][2]int, v.K)
+				for n := 0; n < v.K; n++ {
+					line[n] = [2]int{i + d[0]*n, j + d[1]*n}
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// Reason is the reason a game ended, other than by k in a row or a full
+// board.
+type Reason string
+
+// Reasons.
+const (
+	ReasonTimeout   Reason = "timeout"
+	ReasonAbandoned Reason = "abandoned"
+	ReasonResigned  Reason = "resigned"
+	// ReasonAgreed is a draw agreed by both players.
+	ReasonAgreed Reason = "agreed"
+)
+
+// TimeControl is a time control, in ticks. A zero value disables the clock.
+type TimeControl struct {
+	// Game is each player's total clock.
+	Game int `json:"game,omitempty"`
+	// Turn is the clock for each turn.
+	Turn int `json:"turn,omitempty"`
+	// Increment is added to the player's total clock after each move
+	// (Fischer increment).
+	Increment int `json:"increment,omitempty"`
 }
 
 type State struct {
-	Cells            [][]int  `json:"cells,omitempty"`
-	PlayerTurn       int      `json:"player_turn"`
-	Players          []Player `json:"players"`
-	Winner           Winner   `json:"winner,omitempty"`
-	Draw             bool     `json:"draw,omitempty"`
-	RematchCountdown int      `json:"rematch_countdown,omitempty"`
+	Variant
+	Cells            [][]int      `json:"cells,omitempty"`
+	PlayerTurn       int          `json:"player_turn"`
+	Players          []Player     `json:"players"`
+	Winner           Winner       `json:"winner,omitempty"`
+	Draw             bool         `json:"draw,omitempty"`
+	Reason           Reason       `json:"reason,omitempty"`
+	RematchCountdown int          `json:"rematch_countdown,omitempty"`
+	TimeControl      *TimeControl `json:"time_control,omitempty"`
+	// Clocks are the remaining total clocks for each player, as of the last
+	// broadcast. Clients count down the player to move's clock locally.
+	Clocks []int `json:"clocks,omitempty"`
+	// TurnClock is the remaining clock for the current turn.
+	TurnClock int `json:"turn_clock,omitempty"`
+	// Moves are the moves played, in order.
+	Moves []Move `json:"moves,omitempty"`
+	// Casual is set for unrated games, which permit takebacks.
+	Casual bool `json:"casual,omitempty"`
+	// Takeback is the player requesting a takeback, pending the other
+	// player's response.
+	Takeback int `json:"takeback,omitempty"`
+	// DrawOffer is the player offering a draw, pending the other player's
+	// response.
+	DrawOffer int `json:"draw_offer,omitempty"`
+	// Rematch are the players' votes for a rematch, during the rematch
+	// countdown.
+	Rematch []bool `json:"rematch,omitempty"`
+	// Series is the score of the games played between the players.
+	Series *Series `json:"series,omitempty"`
+	// Finished is set when no further games will be played in the match.
+	Finished bool `json:"finished,omitempty"`
+}
+
+// Series is the score of a series of games between the same players.
+type Series struct {
+	// BestOf is the number of games in the series, or 0 for a series without
+	// a limit.
+	BestOf int `json:"best_of,omitempty"`
+	Games  int `json:"games"`
+	// Wins are the games won by each player.
+	Wins  []int `json:"wins"`
+	Draws int   `json:"draws,omitempty"`
+}
+
+// ValidBestOf returns an error when n is not a valid series length.
+func ValidBestOf(n int) error {
+	if n < 0 || (n != 0 && n%2 == 0) {
+		return fmt.Errorf("invalid best of %d", n)
+	}
+	return nil
+}
+
+// NewSeries creates a new best of n series.
+func NewSeries(n int) *Series {
+	return &Series{
+		BestOf: n,
+		Wins:   []int{0, 0},
+	}
+}
+
+// Add adds a game's result to the series.
+func (s *Series) Add(winner Winner) {
+	s.Games++
+	switch winner {
+	case 1, 2:
+		s.Wins[winner-1]++
+	default:
+		s.Draws++
+	}
+}
+
+// Winner returns the player that won the majority of a best of n series.
+func (s *Series) Winner() Winner {
+	for i, w := range s.Wins {
+		if s.BestOf != 0 && w > s.BestOf/2 {
+			return Winner(i + 1)
+		}
+	}
+	return 0
 }
 
+// Over returns true when the series has a winner or all its games have been
+// played.
+func (s *Series) Over() bool {
+	return s.BestOf != 0 && (s.Winner() != 0 || s.Games >= s.BestOf)
+}
+
+// String satisfies the fmt.Stringer interface.
+func (s *Series) String() string {
+	str := fmt.Sprintf("%d-%d", s.Wins[0], s.Wins[1])
+	if s.Draws != 0 {
+		str += fmt.Sprintf("-%d", s.Draws)
+	}
+	if s.BestOf != 0 {
+		str += fmt.Sprintf(" (best of %d)", s.BestOf)
+	}
+	return str
+}
+
+// NewState creates a new state for the default variant.
 func NewState() *State {
-	cells := make([][]int, 3)
-	for i := 0; i < 3; i++ {
-		cells[i] = []int{-1, -1, -1}
+	return newState(DefaultVariant)
+}
+
+// NewVariantState creates a new state for the variant.
+func NewVariantState(v Variant) (*State, error) {
+	if err := v.Valid(); err != nil {
+		return nil, err
+	}
+	return newState(v), nil
+}
+
+func newState(v Variant) *State {
+	cells := make([][]int, v.Rows)
+	for i := 0; i < v.Rows; i++ {
+		cells[i] = make([]int, v.Cols)
+		for j := 0; j < v.Cols; j++ {
+			cells[i][j] = -1
+		}
 	}
 	return &State{
+		Variant:    v,
 		Cells:      cells,
 		PlayerTurn: 1,
 	}
 }
 
+// Copy returns a deep copy of the state.
+func (s *State) Copy() *State {
+	c := *s
+	c.Cells = copyCells(s.Cells)
+	c.Players = append([]Player(nil), s.Players...)
+	c.Clocks = append([]int(nil), s.Clocks...)
+	c.Moves = append([]Move(nil), s.Moves...)
+	c.Rematch = append([]bool(nil), s.Rematch...)
+	if s.Series != nil {
+		series := *s.Series
+		series.Wins = append([]int(nil), s.Series.Wins...)
+		c.Series = &series
+	}
+	if s.TimeControl != nil {
+		tc := *s.TimeControl
+		c.TimeControl = &tc
+	}
+	return &c
+}
+
+// SetTimeControl sets the time control, resetting the clocks.
+func (s *State) SetTimeControl(tc TimeControl) {
+	if tc == (TimeControl{}) {
+		s.TimeControl, s.Clocks, s.TurnClock = nil, nil, 0
+		return
+	}
+	s.TimeControl, s.Clocks, s.TurnClock = &tc, nil, tc.Turn
+	if tc.Game != 0 {
+		s.Clocks = []int{tc.Game, tc.Game}
+	}
+}
+
+// Tick advances the player to move's clocks by one tick. When a clock runs
+// out, the player to move forfeits and Tick returns true.
+func (s *State) Tick() bool {
+	if s.TimeControl == nil || s.Winner != 0 || s.Draw || (s.PlayerTurn != 1 && s.PlayerTurn != 2) {
+		return false
+	}
+	expired := false
+	if s.TimeControl.Game != 0 {
+		s.Clocks[s.PlayerTurn-1]--
+		expired = s.Clocks[s.PlayerTurn-1] <= 0
+	}
+	if s.TimeControl.Turn != 0 {
+		s.TurnClock--
+		expired = expired || s.TurnClock <= 0
+	}
+	if expired {
+		s.Winner, s.Reason, s.PlayerTurn = Winner(3-s.PlayerTurn), ReasonTimeout, -1
+	}
+	return expired
+}
+
 func (s *State) Add(node, sessionId, userId, username string) error {
 	if len(s.Players) == 2 {
 		return fmt.Errorf("cannot have more than 2 players in a game")
@@ -84,21 +410,40 @@ func (s *State) Add(node, sessionId, userId, username string) error {
 	return nil
 }
 
+// AddBot adds a bot player.
+func (s *State) AddBot(userId, username string) error {
+	if err := s.Add("", "", userId, username); err != nil {
+		return err
+	}
+	s.Players[len(s.Players)-1].Bot = true
+	return nil
+}
+
+// Seated returns true when the user has a seat in the game.
+func (s *State) Seated(userId string) bool {
+	for _, p := range s.Players {
+		if p.UserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *State) Move(userId string, move Move) error {
 	row, col := move.Row-1, move.Col-1
 	switch {
-	case row < 0 || 3 <= row:
-		return fmt.Errorf("invalid row %d (%d)", move.Row, row)
-	case col < 0 || 3 <= col:
-		return fmt.Errorf("invalid col %d (%d)", move.Col, col)
+	case row < 0 || s.Rows <= row:
+		return fmt.Errorf("%w: invalid row %d (%d)", ErrInvalidMove, move.Row, row)
+	case col < 0 || s.Cols <= col:
+		return fmt.Errorf("%w: invalid col %d (%d)", ErrInvalidMove, move.Col, col)
 	case s.Cells[row][col] != -1:
-		return fmt.Errorf("invalid move at row %d, col %d (%d, %d)", move.Row, move.Col, row, col)
+		return fmt.Errorf("%w: cell at row %d, col %d (%d, %d) is occupied", ErrInvalidMove, move.Row, move.Col, row, col)
 	case s.Winner != 0:
-		return fmt.Errorf("match already won by player %d", s.Winner)
+		return fmt.Errorf("%w: match already won by player %d", ErrGameOver, s.Winner)
 	case s.Draw:
-		return fmt.Errorf("match is a draw")
+		return fmt.Errorf("%w: match is a draw", ErrGameOver)
 	case s.PlayerTurn != 1 && s.PlayerTurn != 2:
-		return fmt.Errorf("invalid player turn")
+		return fmt.Errorf("%w: invalid player turn", ErrGameOver)
 	}
 	i, p, found := 0, 0, false
 	for ; i < len(s.Players); i++ {
@@ -109,11 +454,24 @@ func (s *State) Move(userId string, move Move) error {
 	}
 	switch p = i + 1; {
 	case !found:
-		return fmt.Errorf("unable to locate player with user id %q", userId)
+		return fmt.Errorf("%w: unable to locate player with user id %q", ErrNotSeated, userId)
 	case s.PlayerTurn != p:
-		return fmt.Errorf("it is not player %d's turn, it is player %d's turn", p, s.PlayerTurn)
+		return fmt.Errorf("%w: it is not player %d's turn, it is player %d's turn", ErrNotYourTurn, p, s.PlayerTurn)
 	default:
 		s.Cells[row][col] = p
+		s.Moves = append(s.Moves, Move{Row: move.Row, Col: move.Col})
+		// a move declines a pending takeback, or the other player's draw
+		// offer
+		s.Takeback = 0
+		if s.DrawOffer != p {
+			s.DrawOffer = 0
+		}
+		if s.TimeControl != nil {
+			if s.TimeControl.Game != 0 {
+				s.Clocks[p-1] += s.TimeControl.Increment
+			}
+			s.TurnClock = s.TimeControl.Turn
+		}
 		switch p {
 		case 1:
 			s.PlayerTurn = 2
@@ -122,18 +480,13 @@ func (s *State) Move(userId string, move Move) error {
 		}
 	}
 	// determine if there is a winner
-loop:
-	for p, s.Winner = 1, 0; p <= 2; p++ {
-		for i = 0; i < 8; i++ {
-			if isWinner(p, s.Cells, coords[i]) {
-				s.Winner = Winner(p)
-				break loop
-			}
-		}
+	if s.Winner = 0; isWinner(p, s.Cells, row, col, s.K) {
+		s.Winner = Winner(p)
 	}
 	// check draw
-	for i, s.Draw = 0, s.Winner == 0; s.Draw && i < 9; i++ {
-		s.Draw = s.Draw && s.Cells[i/3][i%3] != -1
+	s.Draw = s.Winner == 0
+	for i = 0; s.Draw && i < s.Rows*s.Cols; i++ {
+		s.Draw = s.Cells[i/s.Cols][i%s.Cols] != -1
 	}
 	if s.Winner != 0 || s.Draw {
 		s.PlayerTurn = -1
@@ -149,26 +502,75 @@ func (s *State) String() string {
 	if len(s.Players) > 1 {
 		p2 = s.Players[1].UserId
 	}
-	v := make([]interface{}, 9)
-	for i := 0; i < 9; i++ {
-		v[i] = getCellAsRune(i/3, i%3, s.Cells)
+	cells := make([]rune, 0, s.Rows*(s.Cols+1))
+	for i := 0; i < s.Rows; i++ {
+		if i != 0 {
+			cells = append(cells, ' ')
+		}
+		for j := 0; j < s.Cols; j++ {
+			cells = append(cells, getCellAsRune(i, j, s.Cells))
+		}
 	}
 	return fmt.Sprintf(
-		"1:%s 2:%s turn:%d winner:%d draw:%t cells:[%c%c%c %c%c%c %c%c%c]",
-		append([]interface{}{
-			p1,
-			p2,
-			s.PlayerTurn,
-			s.Winner.Int(),
-			s.Draw,
-		}, v...)...)
+		"1:%s 2:%s variant:%s turn:%d winner:%d draw:%t cells:[%s]",
+		p1,
+		p2,
+		s.Variant,
+		s.PlayerTurn,
+		s.Winner.Int(),
+		s.Draw,
+		string(cells),
+	)
+}
+
+// Resign resigns the game for the player.
+func (s *State) Resign(p int) error {
+	switch {
+	case p != 1 && p != 2:
+		return fmt.Errorf("%w: invalid player %d", ErrNotSeated, p)
+	case s.PlayerTurn != 1 && s.PlayerTurn != 2:
+		return fmt.Errorf("%w: game is not in progress", ErrGameOver)
+	}
+	s.Winner, s.Reason, s.PlayerTurn = Winner(3-p), ReasonResigned, -1
+	s.Takeback, s.DrawOffer = 0, 0
+	return nil
+}
+
+// Undo takes back the last move, returning the turn to the player that made
+// it.
+func (s *State) Undo() error {
+	if len(s.Moves) == 0 {
+		return fmt.Errorf("%w: no moves to undo", ErrNotAllowed)
+	}
+	move := s.Moves[len(s.Moves)-1]
+	row, col := move.Row-1, move.Col-1
+	p := s.Cells[row][col]
+	s.Cells[row][col] = -1
+	s.Moves = s.Moves[:len(s.Moves)-1]
+	s.PlayerTurn, s.Winner, s.Draw, s.Reason, s.Takeback, s.DrawOffer = p, 0, false, "", 0, 0
+	if s.TimeControl != nil {
+		s.TurnClock = s.TimeControl.Turn
+	}
+	return nil
+}
+
+// Hash returns a hash of the board position and the player to move.
+func (s *State) Hash() string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s:%d:", s.Variant, s.PlayerTurn)
+	for _, row := range s.Cells {
+		for _, c := range row {
+			h.Write([]byte{byte(c + 1)})
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
 }
 
 func (state *State) Available() [][]int {
 	var v [][]int
-	for i := 0; i < 9; i++ {
-		if state.Cells[i/3][i%3] == -1 {
-			v = append(v, []int{i / 3, i % 3})
+	for i := 0; i < state.Rows*state.Cols; i++ {
+		if state.Cells[i/state.Cols][i%state.Cols] == -1 {
+			v = append(v, []int{i / state.Cols, i % state.Cols})
 		}
 	}
 	return v
@@ -184,11 +586,64 @@ func getCellAsRune(i, j int, cells [][]int) rune {
 	return '.'
 }
 
+// PlayerRating is a player's rating and record.
+type PlayerRating struct {
+	UserId string `json:"user_id"`
+	rating.Rating
+	Games  int `json:"games"`
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+// RatingsRequest is the request for the ratings RPC.
+type RatingsRequest struct {
+	UserIds []string `json:"user_ids,omitempty"`
+}
+
+// RatingsResponse is the response for the ratings RPC.
+type RatingsResponse struct {
+	Ratings []PlayerRating `json:"ratings"`
+}
+
+// CreatePrivateRequest is the request for the create private match RPC.
+type CreatePrivateRequest struct {
+	Variant string `json:"variant,omitempty"`
+	// BestOf is the number of games in the series, or 0 for a series without
+	// a limit.
+	BestOf int `json:"best_of,omitempty"`
+	// Rated creates a rated match. Private matches are casual by default.
+	Rated bool `json:"rated,omitempty"`
+}
+
+// CreatePrivateResponse is the response for the create private match RPC.
+type CreatePrivateResponse struct {
+	MatchId string `json:"match_id"`
+	Code    string `json:"code"`
+	// Casual is set when the match does not affect ratings.
+	Casual bool `json:"casual,omitempty"`
+}
+
+// JoinCodeRequest is the request for the join code RPC.
+type JoinCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// JoinCodeResponse is the response for the join code RPC.
+type JoinCodeResponse struct {
+	MatchId string `json:"match_id"`
+}
+
 type MatchState struct {
 	ActivePlayer *Player `json:"active_player,omitempty"`
 	OtherPlayer  *Player `json:"other_player,omitempty"`
 	State        *State  `json:"state,omitempty"`
 	YourTurn     bool    `json:"your_turn"`
+	// Spectator is set on the view of the match sent to spectators.
+	Spectator bool `json:"spectator,omitempty"`
+	// Seq is the state's sequence number, increasing with every state sent
+	// by the match.
+	Seq int64 `json:"seq,omitempty"`
 }
 
 func (m *MatchState) Marshal() ([]byte, error) {
@@ -202,13 +657,34 @@ func (m *MatchState) Marshal() ([]byte, error) {
 
 func (m *MatchState) Unmarshal(buf []byte) error {
 	dec := json.NewDecoder(bytes.NewReader(buf))
-	dec.DisallowUnknownFields()
 	return dec.Decode(m)
 }
 
+// Response is the response to the other player's takeback request or draw
+// offer, or a player's rematch vote.
+type Response struct {
+	Accept bool `json:"accept"`
+}
+
+func (r Response) Marshal() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *Response) Unmarshal(buf []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	return dec.Decode(r)
+}
+
 type Move struct {
 	Row int `json:"row,omitempty"`
 	Col int `json:"col,omitempty"`
+	// Seq is the sequence number of the state the move was made against.
+	Seq int64 `json:"seq,omitempty"`
 }
 
 func NewMove(row, col int) Move {
@@ -229,23 +705,31 @@ func (m Move) Marshal() ([]byte, error) {
 
 func (m *Move) Unmarshal(buf []byte) error {
 	dec := json.NewDecoder(bytes.NewReader(buf))
-	dec.DisallowUnknownFields()
 	return dec.Decode(m)
 }
 
-func isWinner(p int, c [][]int, w [6]int) bool {
-	return c[w[0]][w[1]] == p &&
-		c[w[2]][w[3]] == p &&
-		c[w[4]][w[5]] == p
+// isWinner determines if player p has k in a row through the cell at row,
+// col.
+func isWinner(p int, c [][]int, row, col, k int) bool {
+	for _, d := range directions {
+		n := 1
+		for _, sign := range [2]int{1, -1} {
+			i, j := row+sign*d[0], col+sign*d[1]
+			for 0 <= i && i < len(c) && 0 <= j && j < len(c[i]) && c[i][j] == p {
+				n, i, j = n+1, i+sign*d[0], j+sign*d[1]
+			}
+		}
+		if k <= n {
+			return true
+		}
+	}
+	return false
 }
 
-var coords = [8][6]int{
-	{0, 0, 0, 1, 0, 2}, // row 0
-	{1, 0, 1, 1, 1, 2}, // row 1
-	{2, 0, 2, 1, 2, 2}, // row 2
-	{0, 0, 1, 0, 2, 0}, // col 0
-	{0, 1, 1, 1, 2, 1}, // col 1
-	{0, 2, 1, 2, 2, 2}, // col 2
-	{0, 0, 1, 1, 2, 2}, // top left to bottom right
-	{2, 0, 1, 1, 0, 2}, // bottom left to top right
+// directions are the row, col steps of the lines on the board.
+var directions = [4][2]int{
+	{0, 1},  // row
+	{1, 0},  // col
+	{1, 1},  // top left to bottom right
+	{-1, 1}, // bottom left to top right
 }
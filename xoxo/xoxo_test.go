@@ -0,0 +1,221 @@
+package xoxo_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ascii8/xoxo-go/xoxo"
+)
+
+func TestVariant(t *testing.T) {
+	tests := []struct {
+		variant string
+		moves   [][2]int
+		winner  int
+		draw    bool
+	}{
+		{"4x4x3", [][2]int{{0, 0}, {3, 3}, {1, 1}, {3, 2}, {2, 2}}, 1, false},
+		{"4x4x4", [][2]int{{0, 0}, {3, 3}, {1, 1}, {3, 2}, {2, 2}, {3, 1}, {0, 1}, {3, 0}}, 2, false},
+		{"5x5x4", [][2]int{{4, 0}, {0, 0}, {3, 1}, {0, 1}, {2, 2}, {0, 2}, {1, 3}}, 1, false},
+		{"3x5x3", [][2]int{{0, 2}, {0, 0}, {1, 3}, {2, 0}, {2, 4}}, 1, false},
+		{"15x15x5", [][2]int{{7, 3}, {0, 0}, {7, 4}, {0, 1}, {7, 5}, {0, 2}, {7, 6}, {0, 3}, {7, 7}}, 1, false},
+		{"1x3x2", [][2]int{{0, 0}, {0, 1}, {0, 2}}, 0, true},
+	}
+	for i, v := range tests {
+		test := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			variant, err := xoxo.ParseVariant(test.variant)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			state := newTwoPlayerState(t, variant)
+			for i, move := range test.moves {
+				if err := state.Move(strconv.Itoa(i%2), xoxo.NewMove(move[0], move[1])); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+			}
+			if state.Winner.Int() != test.winner {
+				t.Errorf("expected winner: %d, got: %d", test.winner, state.Winner)
+			}
+			if state.Draw != test.draw {
+				t.Errorf("expected draw: %t, got: %t", test.draw, state.Draw)
+			}
+			if err := state.Move("0", xoxo.NewMove(variant.Rows, 0)); err == nil {
+				t.Errorf("expected error moving outside of board")
+			}
+			t.Logf("state: %s", state)
+		})
+	}
+	for _, s := range []string{"", "3x3", "3x3x3foo", "3x3x3x3", "3 x3x3", "3x3x", "0x3x3", "3x3x4"} {
+		if _, err := xoxo.ParseVariant(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	state.SetTimeControl(xoxo.TimeControl{
+		Game:      5,
+		Turn:      3,
+		Increment: 1,
+	})
+	for i := 0; i < 2; i++ {
+		if state.Tick() {
+			t.Fatalf("expected no forfeit")
+		}
+	}
+	if err := state.Move("0", xoxo.NewMove(1, 1)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []int{4, 5}; !reflect.DeepEqual(state.Clocks, exp) {
+		t.Errorf("expected clocks %v, got: %v", exp, state.Clocks)
+	}
+	if state.TurnClock != 3 {
+		t.Errorf("expected turn clock %d, got: %d", 3, state.TurnClock)
+	}
+	for i := 0; i < 2; i++ {
+		if state.Tick() {
+			t.Fatalf("expected no forfeit")
+		}
+	}
+	if !state.Tick() {
+		t.Fatalf("expected forfeit")
+	}
+	if state.Winner != 1 || state.Reason != xoxo.ReasonTimeout || state.PlayerTurn != -1 {
+		t.Errorf("expected player 1 to win on time, got: %s", state)
+	}
+	if state.Tick() {
+		t.Errorf("expected no forfeit after game end")
+	}
+}
+
+func TestUndo(t *testing.T) {
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	if err := state.Undo(); err == nil {
+		t.Fatalf("expected error")
+	}
+	initial := state.String()
+	var strs []string
+	for i, m := range [][]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}} {
+		strs = append(strs, state.String())
+		if err := state.Move(strconv.Itoa(i%2), xoxo.NewMove(m[0], m[1])); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if state.Winner != 1 || len(state.Moves) != 5 {
+		t.Fatalf("expected player 1 to win after 5 moves, got: %s", state)
+	}
+	for i := len(strs) - 1; i >= 0; i-- {
+		if err := state.Undo(); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if s := state.String(); s != strs[i] {
+			t.Errorf("undo %d expected %s, got: %s", i, strs[i], s)
+		}
+	}
+	if s := state.String(); s != initial || len(state.Moves) != 0 {
+		t.Errorf("expected %s, got: %s", initial, s)
+	}
+}
+
+func TestResign(t *testing.T) {
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	if err := state.Resign(3); err == nil {
+		t.Errorf("expected error")
+	}
+	if err := state.Resign(1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if state.Winner != 2 || state.Reason != xoxo.ReasonResigned || state.PlayerTurn != -1 {
+		t.Errorf("expected player 2 to win by resignation, got: %s", state)
+	}
+	if err := state.Resign(2); err == nil {
+		t.Errorf("expected error after game end")
+	}
+}
+
+func TestSeries(t *testing.T) {
+	if err := xoxo.ValidBestOf(4); err == nil {
+		t.Errorf("expected error for even series length")
+	}
+	series := xoxo.NewSeries(3)
+	for i, w := range []xoxo.Winner{1, 0, 2} {
+		if series.Over() {
+			t.Fatalf("game %d: expected series in progress", i)
+		}
+		series.Add(w)
+	}
+	if !series.Over() || series.Winner() != 0 {
+		t.Errorf("expected drawn series to be over, got: %s", series)
+	}
+	series = xoxo.NewSeries(3)
+	series.Add(2)
+	series.Add(2)
+	if !series.Over() || series.Winner() != 2 {
+		t.Errorf("expected player 2 to win series, got: %s", series)
+	}
+	if s, exp := series.String(), "0-2 (best of 3)"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	series = xoxo.NewSeries(0)
+	for i := 0; i < 10; i++ {
+		series.Add(1)
+	}
+	if series.Over() {
+		t.Errorf("expected open-ended series not to be over")
+	}
+}
+
+func TestMatchError(t *testing.T) {
+	state := newTwoPlayerState(t, xoxo.DefaultVariant)
+	tests := []struct {
+		userId string
+		move   xoxo.Move
+		code   xoxo.ErrorCode
+		exp    error
+	}{
+		{"1", xoxo.NewMove(1, 1), xoxo.ErrorNotYourTurn, xoxo.ErrNotYourTurn},
+		{"2", xoxo.NewMove(1, 1), xoxo.ErrorNotSeated, xoxo.ErrNotSeated},
+		{"0", xoxo.NewMove(4, 1), xoxo.ErrorInvalidMove, xoxo.ErrInvalidMove},
+	}
+	for i, test := range tests {
+		err := state.Move(test.userId, test.move)
+		if err == nil {
+			t.Fatalf("test %d expected error", i)
+		}
+		buf, err := xoxo.NewMatchError(xoxo.OpCodeMove, err, &test.move).Marshal()
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		merr := new(xoxo.MatchError)
+		if err := merr.Unmarshal(buf); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if merr.Code != test.code || merr.OpCode != xoxo.OpCodeMove || merr.Move == nil || *merr.Move != test.move {
+			t.Errorf("test %d expected %s for move %v, got: %+v", i, test.code, test.move, merr)
+		}
+		if !errors.Is(merr, test.exp) {
+			t.Errorf("test %d expected match error to wrap %s", i, test.code)
+		}
+	}
+}
+
+// newTwoPlayerState creates a state for the variant with players "0" and "1"
+// seated.
+func newTwoPlayerState(t testing.TB, variant xoxo.Variant) *xoxo.State {
+	t.Helper()
+	state, err := xoxo.NewVariantState(variant)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := state.Add("", "", strconv.Itoa(i), "user"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	return state
+}
@@ -156,36 +156,28 @@ func runMatch(t *testing.T, ctx context.Context, s1, s2 int64, urlstr string, re
 		if err != nil {
 			return err
 		}
-		if err := cl.Join(ctx); err != nil {
-			return err
-		}
-		for cl.Next(ctx) {
-			state := cl.State()
-			t.Logf("player turn %q (%d)", state.ActivePlayer.UserId, state.State.PlayerTurn)
-			r := r1
-			if state.State.PlayerTurn == 2 {
-				r = r2
-			}
-			v := state.State.Available()
-			if len(v) == 0 {
-				break
-			}
-			n := r.Intn(len(v))
-			t.Logf(
-				"player %d available %d, choosing move %d (%d, %d)",
-				state.State.PlayerTurn, len(v), n, v[n][0], v[n][1],
-			)
-			if err := cl.Move(ctx, v[n][0], v[n][1]); err != nil {
-				return err
-			}
+		strategies := []xoxo.Strategy{xoxo.NewRandom(r1), xoxo.NewRandom(r2)}
+		strategy := func(ctx context.Context, state *xoxo.State) (xoxo.Move, error) {
+			return strategies[state.PlayerTurn-1].ChooseMove(ctx, state)
 		}
-		if state := cl.State(); state != nil && res != nil {
-			res.draw = state.State.Draw
-			res.winner = state.State.Winner.Int()
-			res.cells = make([]int, 9)
-			copy(res.cells[0:3], state.State.Cells[0][:])
-			copy(res.cells[3:6], state.State.Cells[1][:])
-			copy(res.cells[6:9], state.State.Cells[2][:])
+		b := xoxo.NewBot(
+			cl, xoxo.StrategyFunc(strategy),
+			xoxo.WithBotGames(1),
+			xoxo.WithBotLogf(t.Logf),
+			xoxo.WithBotResult(func(_ int, state *xoxo.MatchState) {
+				if res == nil {
+					return
+				}
+				res.draw = state.State.Draw
+				res.winner = state.State.Winner.Int()
+				res.cells = make([]int, 9)
+				copy(res.cells[0:3], state.State.Cells[0][:])
+				copy(res.cells[3:6], state.State.Cells[1][:])
+				copy(res.cells[6:9], state.State.Cells[2][:])
+			}),
+		)
+		if err := b.Run(ctx); err != nil {
+			return err
 		}
 		if err := cl.Leave(ctx); err != nil {
 			return err